@@ -0,0 +1 @@
+ALTER TABLE analytics MODIFY SETTING non_replicated_deduplication_window = 1000;
@@ -0,0 +1,11 @@
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS country_code LowCardinality(String) AFTER country;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS state_code LowCardinality(String) AFTER state;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS city LowCardinality(String) AFTER state_code;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS continent LowCardinality(String) AFTER city;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS latitude Float64 AFTER continent;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS longitude Float64 AFTER latitude;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS accuracy_radius UInt16 AFTER longitude;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS time_zone LowCardinality(String) AFTER accuracy_radius;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS postal_code String AFTER time_zone;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS asn UInt32;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS as_org LowCardinality(String);
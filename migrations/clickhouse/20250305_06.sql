@@ -0,0 +1,3 @@
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS is_bot Bool DEFAULT false;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS bot_category LowCardinality(String) AFTER is_bot;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS bot_name LowCardinality(String) AFTER bot_category;
@@ -0,0 +1,4 @@
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS browser_version LowCardinality(String) AFTER browser;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS os_version LowCardinality(String) AFTER os;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS device_brand LowCardinality(String) AFTER device_type;
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS device_model LowCardinality(String) AFTER device_brand;
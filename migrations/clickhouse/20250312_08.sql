@@ -0,0 +1 @@
+ALTER TABLE analytics ADD COLUMN IF NOT EXISTS visitor_id UInt64;
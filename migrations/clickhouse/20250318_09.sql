@@ -0,0 +1,127 @@
+CREATE TABLE IF NOT EXISTS analytics_hourly (
+    code String,
+    period DateTime,
+    browser LowCardinality(String),
+    os LowCardinality(String),
+    device_type LowCardinality(String),
+    country LowCardinality(String),
+    state LowCardinality(String),
+    referrer String,
+    has_referrer UInt8,
+    is_bot Bool,
+    clicks SimpleAggregateFunction(sum, UInt64),
+    visitors AggregateFunction(uniqCombinedIf, UInt64, UInt8)
+) ENGINE = AggregatingMergeTree()
+PARTITION BY toYYYYMM(period)
+ORDER BY (code, period, is_bot, device_type, browser, os, country, state, has_referrer, referrer)
+SETTINGS non_replicated_deduplication_window = 1000;
+
+CREATE TABLE IF NOT EXISTS analytics_daily (
+    code String,
+    period DateTime,
+    browser LowCardinality(String),
+    os LowCardinality(String),
+    device_type LowCardinality(String),
+    country LowCardinality(String),
+    state LowCardinality(String),
+    referrer String,
+    has_referrer UInt8,
+    is_bot Bool,
+    clicks SimpleAggregateFunction(sum, UInt64),
+    visitors AggregateFunction(uniqCombinedIf, UInt64, UInt8)
+) ENGINE = AggregatingMergeTree()
+PARTITION BY toYYYYMM(period)
+ORDER BY (code, period, is_bot, device_type, browser, os, country, state, has_referrer, referrer)
+SETTINGS non_replicated_deduplication_window = 1000;
+
+CREATE TABLE IF NOT EXISTS analytics_rollup_backfill (
+    step LowCardinality(String),
+    at DateTime
+) ENGINE = MergeTree()
+ORDER BY step;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS analytics_hourly_mv TO analytics_hourly AS
+SELECT
+    code,
+    toStartOfHour(created_at, 'UTC') AS period,
+    browser,
+    os,
+    device_type,
+    country,
+    state,
+    domain(referer) AS referrer,
+    referer != '' AND lowerUTF8(trim(referer)) NOT IN ('null', '-', '(null)', 'about:blank') AS has_referrer,
+    is_bot,
+    count() AS clicks,
+    uniqCombinedIfState(visitor_id, visitor_id != 0) AS visitors
+FROM analytics
+WHERE created_at >= (SELECT coalesce(minOrNull(at), toDateTime('2106-01-01 00:00:00')) FROM analytics_rollup_backfill WHERE step = 'cutoff')
+GROUP BY code, period, browser, os, device_type, country, state, referrer, has_referrer, is_bot;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS analytics_daily_mv TO analytics_daily AS
+SELECT
+    code,
+    toStartOfDay(created_at, 'UTC') AS period,
+    browser,
+    os,
+    device_type,
+    country,
+    state,
+    domain(referer) AS referrer,
+    referer != '' AND lowerUTF8(trim(referer)) NOT IN ('null', '-', '(null)', 'about:blank') AS has_referrer,
+    is_bot,
+    count() AS clicks,
+    uniqCombinedIfState(visitor_id, visitor_id != 0) AS visitors
+FROM analytics
+WHERE created_at >= (SELECT coalesce(minOrNull(at), toDateTime('2106-01-01 00:00:00')) FROM analytics_rollup_backfill WHERE step = 'cutoff')
+GROUP BY code, period, browser, os, device_type, country, state, referrer, has_referrer, is_bot;
+
+INSERT INTO analytics_rollup_backfill
+SELECT 'cutoff', now()
+WHERE (SELECT count() FROM analytics_rollup_backfill WHERE step = 'cutoff') = 0;
+
+INSERT INTO analytics_hourly
+SELECT
+    code,
+    toStartOfHour(created_at, 'UTC') AS period,
+    browser,
+    os,
+    device_type,
+    country,
+    state,
+    domain(referer) AS referrer,
+    referer != '' AND lowerUTF8(trim(referer)) NOT IN ('null', '-', '(null)', 'about:blank') AS has_referrer,
+    is_bot,
+    count() AS clicks,
+    uniqCombinedIfState(visitor_id, visitor_id != 0) AS visitors
+FROM analytics
+WHERE created_at < (SELECT min(at) FROM analytics_rollup_backfill WHERE step = 'cutoff')
+    AND (SELECT count() FROM analytics_rollup_backfill WHERE step = 'analytics_hourly') = 0
+GROUP BY code, period, browser, os, device_type, country, state, referrer, has_referrer, is_bot;
+
+INSERT INTO analytics_rollup_backfill
+SELECT 'analytics_hourly', now()
+WHERE (SELECT count() FROM analytics_rollup_backfill WHERE step = 'analytics_hourly') = 0;
+
+INSERT INTO analytics_daily
+SELECT
+    code,
+    toStartOfDay(created_at, 'UTC') AS period,
+    browser,
+    os,
+    device_type,
+    country,
+    state,
+    domain(referer) AS referrer,
+    referer != '' AND lowerUTF8(trim(referer)) NOT IN ('null', '-', '(null)', 'about:blank') AS has_referrer,
+    is_bot,
+    count() AS clicks,
+    uniqCombinedIfState(visitor_id, visitor_id != 0) AS visitors
+FROM analytics
+WHERE created_at < (SELECT min(at) FROM analytics_rollup_backfill WHERE step = 'cutoff')
+    AND (SELECT count() FROM analytics_rollup_backfill WHERE step = 'analytics_daily') = 0
+GROUP BY code, period, browser, os, device_type, country, state, referrer, has_referrer, is_bot;
+
+INSERT INTO analytics_rollup_backfill
+SELECT 'analytics_daily', now()
+WHERE (SELECT count() FROM analytics_rollup_backfill WHERE step = 'analytics_daily') = 0;
@@ -100,7 +100,12 @@ func TestAnalyticsE2E(t *testing.T) {
 
 	// 4. Wait for processing (polling ClickHouse)
 	require.Eventually(t, func() bool {
-		summary, err := db.GetAnalytics(context.Background(), conn, testCode, time.Now().Add(-24*time.Hour), time.Now(), "hour")
+		summary, err := db.GetAnalytics(context.Background(), conn, models.AnalyticsQuery{
+			Code:     testCode,
+			Start:    time.Now().Add(-24 * time.Hour),
+			End:      time.Now(),
+			Interval: "hour",
+		})
 		return err == nil && summary.TotalClicks > 0
 	}, 15*time.Second, 500*time.Millisecond)
 
@@ -1,15 +1,24 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	_ "expvar" // registers /debug/vars on http.DefaultServeMux
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/wintkhantlin/url2short-analytics/internal/config"
 	"github.com/wintkhantlin/url2short-analytics/internal/db"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
 )
 
 func Start(conn clickhouse.Conn, cfg *config.Config) {
@@ -17,30 +26,20 @@ func Start(conn clickhouse.Conn, cfg *config.Config) {
 
 	r.SetTrustedProxies(nil)
 
-	r.GET("/:code", func(c *gin.Context) {
-		code := c.Param("code")
-		if code == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
-			return
-		}
+	responses := newResponseCache(cfg)
+	zones := newTimeZones(conn)
 
+	// Account-wide analytics: every alias of the caller combined, or just those listed in
+	// codes, plus a per-alias leaderboard.
+	r.GET("/", func(c *gin.Context) {
 		userID := c.GetHeader("X-User-Id")
 		if userID == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
-		// Verify ownership via Management Service
-		req, err := http.NewRequestWithContext(c.Request.Context(), "GET", fmt.Sprintf("%s/aliases/%s", cfg.ManagementURL, code), nil)
-		if err != nil {
-			slog.Error("Failed to create verification request", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-			return
-		}
-		req.Header.Set("X-User-Id", userID)
-
-		client := &http.Client{Timeout: 5 * time.Second}
-		resp, err := client.Do(req)
+		// One call lists everything the caller owns, however many codes are requested.
+		resp, err := callManagement(c.Request.Context(), cfg, userID, "/aliases")
 		if err != nil {
 			slog.Error("Failed to call management service", "error", err)
 			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Verification service unavailable"})
@@ -49,55 +48,93 @@ func Start(conn clickhouse.Conn, cfg *config.Config) {
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
-			if resp.StatusCode == http.StatusNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Alias not found or access denied"})
-				return
-			}
 			slog.Warn("Management service returned error", "status", resp.StatusCode)
 			c.JSON(resp.StatusCode, gin.H{"error": "Verification failed"})
 			return
 		}
 
-		// Parse query params
-		startStr := c.Query("start")
-		endStr := c.Query("end")
-		interval := c.Query("interval")
-
-		now := time.Now()
-		var start, end time.Time
+		var aliases []struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&aliases); err != nil {
+			slog.Error("Failed to decode management service response", "error", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Verification failed"})
+			return
+		}
+		owned := make([]string, 0, len(aliases))
+		for _, alias := range aliases {
+			owned = append(owned, alias.Code)
+		}
 
-		if endStr != "" {
-			end, err = time.Parse(time.RFC3339, endStr)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end time format (RFC3339 required)"})
+		codes := owned
+		if requested := queryList(c, "codes"); len(requested) > 0 {
+			if codes = ownedCodes(requested, owned); codes == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Alias not found or access denied"})
 				return
 			}
-		} else {
-			end = now
 		}
 
-		if startStr != "" {
-			start, err = time.Parse(time.RFC3339, startStr)
+		req, err := parseAnalyticsRequest(c, cfg, zones)
+		if err != nil {
+			badRequest(c, err)
+			return
+		}
+		req.setCodes(codes)
+
+		serveCached(c, responses, cfg, "account", req, func(ctx context.Context) (any, error) {
+			analyticsResp, err := getAnalytics(ctx, conn, req)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start time format (RFC3339 required)"})
-				return
+				slog.Error("Failed to get analytics", "error", err, "codes", len(codes))
+				return nil, err
+			}
+			leaderboard, err := db.GetLeaderboard(ctx, conn, req.query)
+			if err != nil {
+				slog.Error("Failed to get alias leaderboard", "error", err, "codes", len(codes))
+				return nil, err
 			}
-		} else {
-			start = end.Add(-24 * time.Hour)
+			return models.AccountAnalyticsResponse{
+				AnalyticsResponse: *analyticsResp,
+				Aliases:           leaderboard,
+			}, nil
+		})
+	})
+
+	r.GET("/:code", func(c *gin.Context) {
+		code := c.Param("code")
+		if code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+			return
 		}
 
-		if interval == "" {
-			interval = "hour"
+		if !verifyAlias(c, cfg, code) {
+			return
 		}
 
-		analyticsResp, err := db.GetAnalytics(c.Request.Context(), conn, code, start, end, interval)
+		req, err := parseAnalyticsRequest(c, cfg, zones)
 		if err != nil {
-			slog.Error("Failed to get analytics", "error", err, "code", code)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get analytics"})
+			badRequest(c, err)
 			return
 		}
+		req.setCode(code)
 
-		c.JSON(http.StatusOK, analyticsResp)
+		// Ownership is checked above on every request; only the figures are cached.
+		serveCached(c, responses, cfg, "code", req, func(ctx context.Context) (any, error) {
+			analyticsResp, err := getAnalytics(ctx, conn, req)
+			if err != nil {
+				slog.Error("Failed to get analytics", "error", err, "code", code)
+				return nil, err
+			}
+			return analyticsResp, nil
+		})
+	})
+
+	// Raw click events as CSV, NDJSON or Parquet, streamed straight from ClickHouse.
+	r.GET("/:code/export", func(c *gin.Context) {
+		code := c.Param("code")
+		if !verifyAlias(c, cfg, code) {
+			return
+		}
+		exportEvents(c, conn, code)
 	})
 
 	slog.Info("Analytics API (Gin) listening", "port", cfg.APIPort)
@@ -105,3 +142,304 @@ func Start(conn clickhouse.Conn, cfg *config.Config) {
 		slog.Error("Failed to start API server", "error", err)
 	}
 }
+
+// verifyAlias checks with the Management Service that the caller (X-User-Id) owns code.
+// When they don't, or the check fails, it writes the error response and returns false.
+func verifyAlias(c *gin.Context, cfg *config.Config, code string) bool {
+	userID := c.GetHeader("X-User-Id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+
+	resp, err := callManagement(c.Request.Context(), cfg, userID, "/aliases/"+url.PathEscape(code))
+	if err != nil {
+		slog.Error("Failed to call management service", "error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Verification service unavailable"})
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alias not found or access denied"})
+			return false
+		}
+		slog.Warn("Management service returned error", "status", resp.StatusCode)
+		c.JSON(resp.StatusCode, gin.H{"error": "Verification failed"})
+		return false
+	}
+	return true
+}
+
+var managementClient = &http.Client{Timeout: 5 * time.Second}
+
+// callManagement sends a GET for path to the Management Service on behalf of userID.
+func callManagement(ctx context.Context, cfg *config.Config, userID, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", cfg.ManagementURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-User-Id", userID)
+	return managementClient.Do(req)
+}
+
+// ownedCodes returns requested without duplicates, or nil if any of them is not in owned.
+func ownedCodes(requested, owned []string) []string {
+	isOwned := make(map[string]bool, len(owned))
+	for _, code := range owned {
+		isOwned[code] = true
+	}
+	codes := make([]string, 0, len(requested))
+	for _, code := range requested {
+		code = strings.TrimSpace(code)
+		if code == "" || slices.Contains(codes, code) {
+			continue
+		}
+		if !isOwned[code] {
+			return nil
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return codes
+}
+
+// analyticsRequest holds the query parameters shared by the single-alias and account-wide
+// endpoints.
+type analyticsRequest struct {
+	query models.AnalyticsQuery
+	// compareQuery is set when the request asked for a comparison period.
+	compareQuery *models.AnalyticsQuery
+	deviceRollup bool
+}
+
+func (r *analyticsRequest) setCode(code string) {
+	r.query.Code = code
+	if r.compareQuery != nil {
+		r.compareQuery.Code = code
+	}
+}
+
+func (r *analyticsRequest) setCodes(codes []string) {
+	r.query.Codes = codes
+	if r.compareQuery != nil {
+		r.compareQuery.Codes = codes
+	}
+}
+
+// parseAnalyticsRequest reads the time range, interval, filters, comparison and rollup
+// parameters. Any error is the client's.
+func parseAnalyticsRequest(c *gin.Context, cfg *config.Config, zones *timeZones) (analyticsRequest, error) {
+	var req analyticsRequest
+
+	start, end, err := parseRange(c)
+	if err != nil {
+		return req, err
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		return req, err
+	}
+
+	// Buckets follow the viewer's calendar: tz is an IANA zone such as Asia/Yangon and
+	// week_start=monday switches to ISO weeks.
+	req.query = models.AnalyticsQuery{
+		Start:     start,
+		End:       end,
+		Interval:  c.Query("interval"),
+		TimeZone:  c.Query("tz"),
+		WeekStart: models.WeekStart(c.Query("week_start")),
+		Filters:   filters,
+	}
+	if err := req.query.Normalize(); err != nil {
+		return req, err
+	}
+	if err := zones.check(c.Request.Context(), req.query.TimeZone); err != nil {
+		return req, err
+	}
+	if err := checkTimelinePoints(req.query, cfg.MaxTimelinePoints); err != nil {
+		return req, err
+	}
+	// Devices are broken down by class; device_rollup=true folds them into mobile/desktop.
+	if req.deviceRollup, err = queryBool(c, "device_rollup"); err != nil {
+		return req, err
+	}
+
+	// compare=previous|year|custom adds the same figures for an earlier period plus
+	// deltas; custom takes compare_start and compare_end.
+	if mode := c.Query("compare"); mode != "" {
+		if req.compareQuery, err = parseComparison(c, req.query, mode); err != nil {
+			return req, err
+		}
+		if err := checkTimelinePoints(*req.compareQuery, cfg.MaxTimelinePoints); err != nil {
+			return req, err
+		}
+	}
+	return req, nil
+}
+
+// parseRange reads start and end (RFC3339). end defaults to now and start to 24 hours
+// before end.
+func parseRange(c *gin.Context) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+
+	if endStr := c.Query("end"); endStr != "" {
+		end, err = time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			return start, end, fmt.Errorf("Invalid end time format (RFC3339 required)")
+		}
+	} else {
+		end = time.Now()
+	}
+
+	if startStr := c.Query("start"); startStr != "" {
+		start, err = time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			return start, end, fmt.Errorf("Invalid start time format (RFC3339 required)")
+		}
+	} else {
+		start = end.Add(-24 * time.Hour)
+	}
+	return start, end, nil
+}
+
+// badRequest reports a client error. An unknown time zone also gets a code, so the web
+// UI can tell it apart and retry in UTC.
+func badRequest(c *gin.Context, err error) {
+	body := gin.H{"error": err.Error()}
+	if errors.Is(err, models.ErrUnknownTimeZone) {
+		body["code"] = "unknown_time_zone"
+	}
+	c.JSON(http.StatusBadRequest, body)
+}
+
+// queryFailed reports a failed analytics query: 504 when ctx ran out of
+// cfg.QueryTimeout, 500 otherwise.
+func queryFailed(ctx context.Context, c *gin.Context) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "analytics query timed out"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get analytics"})
+}
+
+// getAnalytics runs req's query and, if requested, its comparison.
+func getAnalytics(ctx context.Context, conn clickhouse.Conn, req analyticsRequest) (*models.AnalyticsResponse, error) {
+	analyticsResp, err := db.GetAnalytics(ctx, conn, req.query)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous *models.AnalyticsResponse
+	if req.compareQuery != nil {
+		// Fetch exactly the rows the primary period returned, not the comparison
+		// period's own top 10, so every delta has both sides.
+		compareQuery := *req.compareQuery
+		compareQuery.Breakdowns = models.BreakdownNames(analyticsResp)
+		previous, err = db.GetAnalytics(ctx, conn, compareQuery)
+		if err != nil {
+			return nil, fmt.Errorf("comparison period: %w", err)
+		}
+	}
+
+	if req.deviceRollup {
+		analyticsResp.Devices = models.RollupDevices(analyticsResp.Devices)
+		if previous != nil {
+			previous.Devices = models.RollupDevices(previous.Devices)
+		}
+	}
+	if previous != nil {
+		analyticsResp.Comparison = models.Compare(analyticsResp, previous, req.query, *req.compareQuery)
+	}
+	return analyticsResp, nil
+}
+
+// StartMetrics serves expvar metrics (cache hit/miss counters, memstats) at /debug/vars.
+// It listens on a separate port so the endpoint is not reachable through the public
+// analytics route.
+func StartMetrics(cfg *config.Config) {
+	slog.Info("Metrics listening", "port", cfg.MetricsPort)
+	if err := http.ListenAndServe(fmt.Sprintf(":%s", cfg.MetricsPort), nil); err != nil {
+		slog.Error("Failed to start metrics server", "error", err)
+	}
+}
+
+// queryBool reads an optional boolean query parameter; a missing parameter is false.
+func queryBool(c *gin.Context, name string) (bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
+
+// parseFilters reads the dimension filters: "country=germany,france" keeps only those
+// values and "exclude_country=germany" drops them, for each of models.FilterDimensions.
+// Parameters may also be repeated. Bots (crawlers, link previews, monitors) are excluded
+// unless bots=include or bots=only is passed; include_bots=true is accepted as well.
+func parseFilters(c *gin.Context) (models.AnalyticsFilters, error) {
+	var filters models.AnalyticsFilters
+	for _, name := range models.FilterDimensions {
+		dim := filters.Dimension(name)
+		dim.Include = queryList(c, name)
+		dim.Exclude = queryList(c, "exclude_"+name)
+	}
+
+	filters.Bots = models.BotFilter(c.Query("bots"))
+	includeBots, err := queryBool(c, "include_bots")
+	if err != nil {
+		return filters, err
+	}
+	if includeBots && filters.Bots == "" {
+		filters.Bots = models.BotsInclude
+	}
+	return filters, nil
+}
+
+// queryList collects a comma-separated and/or repeated query parameter.
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, v := range c.QueryArray(name) {
+		values = append(values, strings.Split(v, ",")...)
+	}
+	return values
+}
+
+// checkTimelinePoints rejects queries whose gap-filled timeline would be absurdly long,
+// such as a minute interval over a year.
+func checkTimelinePoints(q models.AnalyticsQuery, max int) error {
+	if points := q.TimelinePoints(); points > max {
+		return fmt.Errorf("range too long for interval %q: %d points, at most %d allowed; use a larger interval", q.Interval, points, max)
+	}
+	return nil
+}
+
+// parseComparison returns q moved to the comparison period selected by mode.
+func parseComparison(c *gin.Context, q models.AnalyticsQuery, mode string) (*models.AnalyticsQuery, error) {
+	var customStart, customEnd time.Time
+	if mode == models.CompareCustom {
+		var err error
+		if customStart, err = time.Parse(time.RFC3339, c.Query("compare_start")); err != nil {
+			return nil, fmt.Errorf("invalid compare_start (RFC3339 required)")
+		}
+		if customEnd, err = time.Parse(time.RFC3339, c.Query("compare_end")); err != nil {
+			return nil, fmt.Errorf("invalid compare_end (RFC3339 required)")
+		}
+	}
+
+	start, end, err := models.ComparisonRange(q, mode, customStart, customEnd)
+	if err != nil {
+		return nil, err
+	}
+	q.Start, q.End = start, end
+	return &q, nil
+}
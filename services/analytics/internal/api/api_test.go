@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+func TestOwnedCodes(t *testing.T) {
+	owned := []string{"abc", "def", "ghi"}
+
+	assert.Equal(t, []string{"def", "abc"}, ownedCodes([]string{"def", " abc", "def", ""}, owned))
+	assert.Nil(t, ownedCodes([]string{"abc", "xyz"}, owned), "one foreign code rejects the request")
+	assert.Nil(t, ownedCodes([]string{""}, owned))
+}
+
+func TestExportWriters(t *testing.T) {
+	rows := []models.ExportRow{
+		{Timestamp: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), Country: "germany", Browser: "chrome", OS: "android", Device: "mobile", Referrer: "google.com"},
+		{Timestamp: time.Date(2025, 3, 1, 12, 5, 0, 0, time.UTC), Country: "france", State: "île-de-france", Browser: "safari", OS: "ios", Device: "tablet"},
+	}
+	write := func(t *testing.T, format string) []byte {
+		var buf bytes.Buffer
+		w, err := exportFormats[format].newWriter(&buf)
+		require.NoError(t, err)
+		for _, row := range rows {
+			require.NoError(t, w.Write(row))
+		}
+		require.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		assert.Equal(t, "timestamp,country,state,browser,os,device,referrer\n"+
+			"2025-03-01T12:00:00Z,germany,,chrome,android,mobile,google.com\n"+
+			"2025-03-01T12:05:00Z,france,île-de-france,safari,ios,tablet,\n", string(write(t, "csv")))
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(string(write(t, "ndjson"))), "\n")
+		require.Len(t, lines, 2)
+		assert.JSONEq(t, `{"timestamp":"2025-03-01T12:00:00Z","country":"germany","state":"","browser":"chrome","os":"android","device":"mobile","referrer":"google.com"}`, lines[0])
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		data := write(t, "parquet")
+		got, err := parquet.Read[models.ExportRow](bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		assert.Equal(t, rows, got)
+	})
+}
+
+func TestStreamExport_AbortsOnFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// gin.Default's Recovery must not turn a failed export into a complete-looking file.
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.GET("/:rows", func(c *gin.Context) {
+		failAfter := exportFlushRows + 5
+		if c.Param("rows") == "all" {
+			failAfter = -1
+		}
+		streamExport(c, exportFormats["csv"], "csv", "abc", start, func(fn func(models.ExportRow) error) error {
+			for i := 0; i < 2*exportFlushRows; i++ {
+				if i == failAfter {
+					return errors.New("clickhouse went away")
+				}
+				if err := fn(models.ExportRow{Timestamp: start, Country: "germany"}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	download := func(path string) (int, int, error) {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp.StatusCode, strings.Count(string(body), "\n"), err
+	}
+
+	status, lines, err := download("/all")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2*exportFlushRows+1, lines)
+
+	status, lines, err = download("/partial")
+	assert.Equal(t, http.StatusOK, status)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "a failed export must not end like a complete one")
+	assert.GreaterOrEqual(t, lines, exportFlushRows)
+}
+
+func TestQueryFailed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	status := func(ctx context.Context) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		queryFailed(ctx, c)
+		return w.Code
+	}
+
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+	assert.Equal(t, http.StatusGatewayTimeout, status(expired))
+	assert.Equal(t, http.StatusInternalServerError, status(context.Background()))
+}
+
+func TestTimeZones_Check(t *testing.T) {
+	loads := 0
+	fail := true
+	zones := &timeZones{load: func(context.Context) ([]string, error) {
+		loads++
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return []string{"UTC", "Asia/Yangon"}, nil
+	}}
+	ctx := context.Background()
+
+	assert.NoError(t, zones.check(ctx, ""), "no tz needs no lookup")
+	assert.Zero(t, loads)
+
+	assert.NoError(t, zones.check(ctx, "Europe/Kyiv"), "without the set only Go's check applies")
+	fail = false
+	assert.NoError(t, zones.check(ctx, "Asia/Yangon"))
+	assert.Equal(t, 2, loads, "a failed load is retried")
+
+	err := zones.check(ctx, "Europe/Kyiv")
+	assert.ErrorIs(t, err, models.ErrUnknownTimeZone)
+	assert.EqualError(t, err, `unknown time zone "Europe/Kyiv"`)
+	assert.NoError(t, zones.check(ctx, "UTC"))
+	assert.Equal(t, 2, loads, "the set is loaded once")
+}
+
+func TestBadRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	body := func(err error) string {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		badRequest(c, err)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		return w.Body.String()
+	}
+
+	q := models.AnalyticsQuery{Start: time.Now(), End: time.Now(), TimeZone: "Mars/Olympus"}
+	assert.JSONEq(t, `{"error":"unknown time zone \"Mars/Olympus\"","code":"unknown_time_zone"}`, body(q.Normalize()))
+	assert.JSONEq(t, `{"error":"start must not be after end"}`, body(errors.New("start must not be after end")))
+}
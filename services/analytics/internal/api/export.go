@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/parquet-go/parquet-go"
+	"github.com/wintkhantlin/url2short-analytics/internal/db"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+// exportFlushRows is how many rows are written between flushes to the client; for
+// Parquet it is also the row group size.
+const exportFlushRows = 10000
+
+// exportWriter encodes export rows in one format.
+type exportWriter interface {
+	Write(row models.ExportRow) error
+	// Flush sends everything buffered so far to the underlying writer.
+	Flush() error
+	// Close writes any trailer; the writer can't be used afterwards.
+	Close() error
+}
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer) (exportWriter, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":     {"text/csv; charset=utf-8", "csv", newCSVExportWriter},
+	"ndjson":  {"application/x-ndjson", "ndjson", newNDJSONExportWriter},
+	"parquet": {"application/vnd.apache.parquet", "parquet", newParquetExportWriter},
+}
+
+// exportEvents streams code's click events in the format given by the format parameter
+// (csv by default). start, end and the filters work as they do for analytics; bots are
+// left out unless asked for.
+func exportEvents(c *gin.Context, conn clickhouse.Conn, code string) {
+	name := c.DefaultQuery("format", "csv")
+	format, ok := exportFormats[name]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, ndjson or parquet"})
+		return
+	}
+
+	start, end, err := parseRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query := models.AnalyticsQuery{Code: code, Start: start, End: end, Filters: filters}
+	if err := query.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	streamExport(c, format, name, code, start, func(fn func(models.ExportRow) error) error {
+		return db.ExportEvents(c.Request.Context(), conn, query, fn)
+	})
+}
+
+// streamExport writes the rows fetch passes to its callback to the client in format. name
+// and code are only used to name the file and in logs.
+func streamExport(c *gin.Context, format exportFormat, name, code string, start time.Time, fetch func(fn func(models.ExportRow) error) error) {
+	w, err := format.newWriter(c.Writer)
+	if err != nil {
+		slog.Error("Failed to start export", "error", err, "code", code, "format", name)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export events"})
+		return
+	}
+
+	c.Header("Content-Type", format.contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, code, start.UTC().Format("20060102T150405Z"), format.extension))
+	c.Status(http.StatusOK)
+
+	rows := 0
+	err = fetch(func(row models.ExportRow) error {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+		if rows++; rows%exportFlushRows == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		// The status line has gone out already; all we can do is cut the download short
+		// so the client sees an incomplete file rather than a silently truncated one.
+		slog.Error("Export failed", "error", err, "code", code, "format", name, "rows", rows)
+		abortConnection(c)
+	}
+}
+
+// abortConnection drops the client's connection without ending the response, so the
+// chunked body is left unterminated. panic(http.ErrAbortHandler) would not do: gin's
+// Recovery catches it and the body then ends normally. Gin refuses to hijack a response
+// that has been written to, so the connection is taken from the writer underneath.
+func abortConnection(c *gin.Context) {
+	c.Abort()
+	var w http.ResponseWriter = c.Writer
+	if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+		w = u.Unwrap()
+	}
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		slog.Error("Failed to abort export connection", "error", err)
+		return
+	}
+	conn.Close()
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) (exportWriter, error) {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"timestamp", "country", "state", "browser", "os", "device", "referrer"})
+	return &csvExportWriter{w: cw}, err
+}
+
+func (e *csvExportWriter) Write(row models.ExportRow) error {
+	return e.w.Write([]string{
+		row.Timestamp.UTC().Format(time.RFC3339),
+		row.Country,
+		row.State,
+		row.Browser,
+		row.OS,
+		row.Device,
+		row.Referrer,
+	})
+}
+
+func (e *csvExportWriter) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExportWriter) Close() error {
+	return e.Flush()
+}
+
+type ndjsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONExportWriter(w io.Writer) (exportWriter, error) {
+	return &ndjsonExportWriter{enc: json.NewEncoder(w)}, nil
+}
+
+func (e *ndjsonExportWriter) Write(row models.ExportRow) error {
+	row.Timestamp = row.Timestamp.UTC()
+	return e.enc.Encode(row)
+}
+
+// The encoder writes each row straight through.
+func (e *ndjsonExportWriter) Flush() error { return nil }
+func (e *ndjsonExportWriter) Close() error { return nil }
+
+type parquetExportWriter struct {
+	w *parquet.GenericWriter[models.ExportRow]
+}
+
+func newParquetExportWriter(w io.Writer) (exportWriter, error) {
+	return &parquetExportWriter{w: parquet.NewGenericWriter[models.ExportRow](w)}, nil
+}
+
+func (e *parquetExportWriter) Write(row models.ExportRow) error {
+	_, err := e.w.Write([]models.ExportRow{row})
+	return err
+}
+
+// Flush ends the current row group, so at most exportFlushRows rows are held in memory.
+func (e *parquetExportWriter) Flush() error {
+	return e.w.Flush()
+}
+
+func (e *parquetExportWriter) Close() error {
+	return e.w.Close()
+}
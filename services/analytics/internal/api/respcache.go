@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wintkhantlin/url2short-analytics/internal/cache"
+	"github.com/wintkhantlin/url2short-analytics/internal/config"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+// responseCache stores encoded analytics responses. A nil *responseCache caches nothing.
+type responseCache struct {
+	store   cache.Store
+	liveTTL time.Duration
+	// liveWindow is how long after a range ends its figures may still change. Late
+	// events keep arriving through consumer lag, retried inserts and dead-letter
+	// replays, which can run days after the events were rejected.
+	liveWindow    time.Duration
+	historicalTTL time.Duration
+	now           func() time.Time
+}
+
+// newResponseCache uses Redis when cfg names one, so every replica shares the cache,
+// and an in-process LRU otherwise.
+func newResponseCache(cfg *config.Config) *responseCache {
+	rc := &responseCache{
+		liveTTL:       cfg.APICacheLiveTTL,
+		liveWindow:    cfg.APICacheLiveWindow,
+		historicalTTL: cfg.APICacheHistoricalTTL,
+		now:           time.Now,
+	}
+	if cfg.APICacheRedisURL != "" {
+		store, err := cache.NewRedisStore(cfg.APICacheRedisURL)
+		if err == nil {
+			rc.store = store
+			return rc
+		}
+		slog.Warn("Invalid analytics cache Redis URL (falling back to memory)", "error", err)
+	}
+	if cfg.APICacheSize <= 0 {
+		return nil
+	}
+	store := cache.NewMemoryStore(cfg.APICacheSize)
+	cache.Publish("response_cache", store.Stats)
+	rc.store = store
+	return rc
+}
+
+// live reports whether q's range ends recently enough that its figures may still change.
+func (rc *responseCache) live(q models.AnalyticsQuery) bool {
+	return q.End.After(rc.now().Add(-rc.liveWindow))
+}
+
+// key identifies req's response. kind separates endpoints whose responses differ for the
+// same query. Live ranges are rounded to liveTTL, so a dashboard asking for "the last
+// 24 hours" every few seconds keeps hitting the same entry until it expires.
+func (rc *responseCache) key(kind string, req analyticsRequest) string {
+	round := time.Second
+	if rc.live(req.query) && rc.liveTTL > round {
+		round = rc.liveTTL
+	}
+
+	type rangeKey struct{ Start, End time.Time }
+	rangeOf := func(q models.AnalyticsQuery) rangeKey {
+		return rangeKey{q.Start.Truncate(round).UTC(), q.End.Truncate(round).UTC()}
+	}
+
+	// Order doesn't change the result, so it shouldn't change the key either.
+	sorted := func(values []string) []string {
+		values = slices.Clone(values)
+		slices.Sort(values)
+		return values
+	}
+	filters := req.query.Filters
+	for _, name := range models.FilterDimensions {
+		dim := filters.Dimension(name)
+		dim.Include, dim.Exclude = sorted(dim.Include), sorted(dim.Exclude)
+	}
+
+	k := struct {
+		Kind         string
+		Code         string
+		Codes        []string
+		Range        rangeKey
+		Compare      *rangeKey
+		Interval     string
+		TimeZone     string
+		WeekStart    models.WeekStart
+		Filters      models.AnalyticsFilters
+		DeviceRollup bool
+	}{
+		Kind:         kind,
+		Code:         req.query.Code,
+		Codes:        sorted(req.query.Codes),
+		Range:        rangeOf(req.query),
+		Interval:     req.query.Interval,
+		TimeZone:     req.query.TimeZone,
+		WeekStart:    req.query.WeekStart,
+		Filters:      filters,
+		DeviceRollup: req.deviceRollup,
+	}
+	if req.compareQuery != nil {
+		compare := rangeOf(*req.compareQuery)
+		k.Compare = &compare
+	}
+
+	data, _ := json.Marshal(k)
+	sum := sha256.Sum256(data)
+	return "analytics:response:" + hex.EncodeToString(sum[:])
+}
+
+// ttl is how long req's response may be served from the cache.
+func (rc *responseCache) ttl(req analyticsRequest) time.Duration {
+	if rc.live(req.query) {
+		return rc.liveTTL
+	}
+	return rc.historicalTTL
+}
+
+func (rc *responseCache) get(ctx context.Context, key string) ([]byte, bool) {
+	if rc == nil {
+		return nil, false
+	}
+	body, ok, err := rc.store.Get(ctx, key)
+	if err != nil {
+		slog.Warn("Analytics cache read failed", "error", err)
+		return nil, false
+	}
+	return body, ok
+}
+
+func (rc *responseCache) set(ctx context.Context, key string, body []byte, ttl time.Duration) {
+	if rc == nil || ttl <= 0 {
+		return
+	}
+	if err := rc.store.Set(ctx, key, body, ttl); err != nil {
+		slog.Warn("Analytics cache write failed", "error", err)
+	}
+}
+
+// serveCached writes the response for req from the cache, or computes it with
+// cfg.QueryTimeout, caches it and writes it. compute logs its own errors.
+func serveCached(c *gin.Context, rc *responseCache, cfg *config.Config, kind string, req analyticsRequest, compute func(ctx context.Context) (any, error)) {
+	var key string
+	var ttl time.Duration
+	if rc != nil {
+		key, ttl = rc.key(kind, req), rc.ttl(req)
+		if body, ok := rc.get(c.Request.Context(), key); ok {
+			c.Header("X-Cache", "HIT")
+			serveJSON(c, body, ttl)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.QueryTimeout)
+	defer cancel()
+
+	resp, err := compute(ctx)
+	if err != nil {
+		queryFailed(ctx, c)
+		return
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("Failed to encode analytics response", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get analytics"})
+		return
+	}
+
+	if rc != nil {
+		rc.set(c.Request.Context(), key, body, ttl)
+		c.Header("X-Cache", "MISS")
+	}
+	serveJSON(c, body, ttl)
+}
+
+// serveJSON writes body with an ETag, or just 304 Not Modified when the client's
+// If-None-Match already has it. maxAge lets the browser reuse it without asking.
+func serveJSON(c *gin.Context, body []byte, maxAge time.Duration) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// etagMatches implements the weak comparison If-None-Match calls for.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
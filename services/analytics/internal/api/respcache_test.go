@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/wintkhantlin/url2short-analytics/internal/cache"
+	"github.com/wintkhantlin/url2short-analytics/internal/config"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+func testResponseCache(now time.Time) *responseCache {
+	return &responseCache{
+		store:         cache.NewMemoryStore(10),
+		liveTTL:       30 * time.Second,
+		liveWindow:    7 * 24 * time.Hour,
+		historicalTTL: 24 * time.Hour,
+		now:           func() time.Time { return now },
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 40, 0, time.UTC)
+	rc := testResponseCache(now)
+
+	request := func(end time.Time, countries ...string) analyticsRequest {
+		return analyticsRequest{query: models.AnalyticsQuery{
+			Code:     "abc",
+			Start:    end.Add(-24 * time.Hour),
+			End:      end,
+			Interval: "hour",
+			Filters:  models.AnalyticsFilters{Country: models.DimensionFilter{Include: countries}},
+		}}
+	}
+
+	live := request(now, "germany", "france")
+	assert.Equal(t, rc.key("code", live), rc.key("code", request(now.Add(-9*time.Second), "france", "germany")),
+		"live ranges within the same TTL window and reordered filters share a key")
+	assert.NotEqual(t, rc.key("code", live), rc.key("code", request(now.Add(-11*time.Second), "germany", "france")))
+	assert.NotEqual(t, rc.key("code", live), rc.key("account", live))
+	assert.Equal(t, []string{"germany", "france"}, live.query.Filters.Country.Include, "the request is not modified")
+
+	historical := request(now.Add(-8 * 24 * time.Hour))
+	assert.NotEqual(t, rc.key("code", historical), rc.key("code", request(now.Add(-8*24*time.Hour-time.Second))))
+
+	assert.Equal(t, 30*time.Second, rc.ttl(live))
+	// A dead-letter replay can still add events to a range that ended days ago.
+	assert.Equal(t, 30*time.Second, rc.ttl(request(now.Add(-2*24*time.Hour))))
+	assert.Equal(t, 24*time.Hour, rc.ttl(historical))
+}
+
+func TestServeCached(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rc := testResponseCache(time.Now())
+	cfg := &config.Config{QueryTimeout: time.Second}
+	req := analyticsRequest{query: models.AnalyticsQuery{Code: "abc", End: time.Now()}}
+
+	computed := 0
+	serve := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/abc", nil)
+		if ifNoneMatch != "" {
+			c.Request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		serveCached(c, rc, cfg, "code", req, func(context.Context) (any, error) {
+			computed++
+			return models.AnalyticsResponse{TotalClicks: 7}, nil
+		})
+		return w
+	}
+
+	first := serve("")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+	assert.Equal(t, "private, max-age=30", first.Header().Get("Cache-Control"))
+	assert.Contains(t, first.Body.String(), `"total_clicks":7`)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	second := serve("")
+	assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, etag, second.Header().Get("ETag"))
+	assert.Equal(t, 1, computed)
+
+	notModified := serve(`"other", W/` + etag)
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/wintkhantlin/url2short-analytics/internal/db"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+// timeZones is the set of zones ClickHouse can load, fetched on first use. Go accepts
+// some zones ClickHouse does not, and those would fail every query with a 500.
+type timeZones struct {
+	load func(ctx context.Context) ([]string, error)
+
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func newTimeZones(conn clickhouse.Conn) *timeZones {
+	return &timeZones{load: func(ctx context.Context) ([]string, error) {
+		return db.TimeZones(ctx, conn)
+	}}
+}
+
+// check returns models.ErrUnknownTimeZone if ClickHouse cannot load name. Until the set
+// has been loaded only Go's check in Normalize applies; a failed load is retried on the
+// next request.
+func (z *timeZones) check(ctx context.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.names == nil {
+		zones, err := z.load(ctx)
+		if err != nil {
+			slog.Warn("Failed to load ClickHouse time zones", "error", err)
+			return nil
+		}
+		z.names = make(map[string]bool, len(zones))
+		for _, zone := range zones {
+			z.names[zone] = true
+		}
+	}
+	if !z.names[name] {
+		return fmt.Errorf("%w %q", models.ErrUnknownTimeZone, name)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"container/list"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LRU is a fixed-size, concurrency-safe least-recently-used cache whose entries also
+// expire after a TTL. A nil *LRU is a valid, always-missing cache.
+type LRU[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	items   map[K]*list.Element
+	order   *list.List
+	now     func() time.Time
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	evicted atomic.Uint64
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// Stats is a point-in-time snapshot of cache counters.
+type Stats struct {
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// New returns a cache holding at most size entries, each valid for ttl. It returns nil
+// (caching disabled) when size is not positive; a zero ttl means entries never expire.
+func New[K comparable, V any](size int, ttl time.Duration) *LRU[K, V] {
+	if size <= 0 {
+		return nil
+	}
+	return &LRU[K, V]{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[K]*list.Element, size),
+		order: list.New(),
+		now:   time.Now,
+	}
+}
+
+// Get returns the cached value for key and marks it as recently used.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+	if !e.expires.IsZero() && c.now().After(e.expires) {
+		c.removeElement(el)
+		c.misses.Add(1)
+		return zero, false
+	}
+
+	c.order.MoveToFront(el)
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Add stores value under key, evicting the least recently used entry when full.
+func (c *LRU[K, V]) Add(key K, value V) {
+	if c == nil {
+		return
+	}
+	c.AddWithTTL(key, value, c.ttl)
+}
+
+// AddWithTTL is Add with a TTL for this entry instead of the cache's; zero never expires.
+func (c *LRU[K, V]) AddWithTTL(key K, value V, ttl time.Duration) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.now().Add(ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value = value
+		e.expires = expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		c.evicted.Add(1)
+	}
+}
+
+// Stats returns the current counters.
+func (c *LRU[K, V]) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Size:      size,
+		Capacity:  c.size,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evicted.Load(),
+	}
+}
+
+func (c *LRU[K, V]) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
+
+// Publish exposes the cache's Stats under name in expvar (served at /debug/vars).
+// stats is called on every scrape, so it should read the current cache.
+func Publish(name string, stats func() Stats) {
+	expvar.Publish(name, expvar.Func(func() any { return stats() }))
+}
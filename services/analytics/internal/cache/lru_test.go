@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int](2, time.Minute)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	_, _ = c.Get("a") // "b" is now the oldest
+	c.Add("c", 3)
+
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	v, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+
+	assert.Equal(t, Stats{Size: 2, Capacity: 2, Hits: 3, Misses: 1, Evictions: 1}, c.Stats())
+}
+
+func TestLRU_ExpiresEntries(t *testing.T) {
+	c := New[string, int](10, time.Minute)
+	now := time.Date(2025, 2, 14, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1)
+
+	now = now.Add(30 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(31 * time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Stats().Size)
+}
+
+func TestLRU_NilIsDisabled(t *testing.T) {
+	c := New[string, int](0, time.Minute)
+	assert.Nil(t, c)
+
+	c.Add("a", 1)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, Stats{}, c.Stats())
+}
+
+func TestLRU_AddWithTTL(t *testing.T) {
+	c := New[string, int](10, 0)
+	now := time.Date(2025, 2, 14, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	c.AddWithTTL("short", 1, time.Second)
+	c.AddWithTTL("long", 2, time.Hour)
+	c.Add("forever", 3)
+
+	now = now.Add(time.Minute)
+	_, ok := c.Get("short")
+	assert.False(t, ok)
+	_, ok = c.Get("long")
+	assert.True(t, ok)
+	_, ok = c.Get("forever")
+	assert.True(t, ok)
+}
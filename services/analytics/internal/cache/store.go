@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store holds encoded values, each with its own TTL. Implementations are safe for
+// concurrent use.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// MemoryStore is a Store local to this process, backed by an LRU.
+type MemoryStore struct {
+	lru *LRU[string, []byte]
+}
+
+// NewMemoryStore returns a Store holding at most size entries.
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{lru: New[string, []byte](size, 0)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	value, ok := s.lru.Get(key)
+	return value, ok, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.lru.AddWithTTL(key, value, ttl)
+	return nil
+}
+
+// Stats returns the counters of the underlying LRU.
+func (s *MemoryStore) Stats() Stats {
+	return s.lru.Stats()
+}
+
+// RedisStore is a Store shared by every process using the same Redis.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects lazily to the Redis at url (redis://host:port/db).
+func NewRedisStore(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -10,28 +12,91 @@ type Config struct {
 	ClickHouseUser     string
 	ClickHousePassword string
 	ClickHouseDB       string
-	KafkaBrokers       []string
-	KafkaTopic         string
-	KafkaGroupID       string
-	APIPort            string
-	ManagementURL      string
-	IP2GeoAddr         string
-	UserAgentAddr      string
+	// InsertRetryBackoff is the first wait between failed batch inserts. InsertDedup sends
+	// an insert_deduplication_token with each batch.
+	InsertRetryBackoff time.Duration
+	InsertDedup        bool
+	// MaxOpenConns caps the ClickHouse connection pool; each analytics request runs its
+	// queries in parallel on up to seven connections.
+	MaxOpenConns int
+	KafkaBrokers []string
+	KafkaTopic   string
+	KafkaGroupID string
+	// KafkaDLQTopic receives events that cannot be decoded, validated or inserted.
+	// Empty disables dead-lettering.
+	KafkaDLQTopic         string
+	KafkaDLQReplayGroupID string
+	// EnrichWorkers is the number of goroutines calling the user-agent and geo services;
+	// EnrichQueueSize bounds how many fetched events may wait between pipeline stages.
+	// EnrichBatchSize caps how many events one worker sends per batch RPC.
+	EnrichWorkers   int
+	EnrichQueueSize int
+	EnrichBatchSize int
+	APIPort         string
+	// MaxTimelinePoints rejects analytics requests whose range/interval would produce a
+	// longer timeline.
+	MaxTimelinePoints int
+	// QueryTimeout bounds all the ClickHouse queries behind one analytics response.
+	QueryTimeout time.Duration
+	// Analytics responses are cached for APICacheLiveTTL when their range ends within
+	// APICacheLiveWindow of now and APICacheHistoricalTTL otherwise, in APICacheRedisURL
+	// or, when that is empty, in memory (up to APICacheSize entries; 0 disables caching).
+	APICacheSize          int
+	APICacheLiveTTL       time.Duration
+	APICacheLiveWindow    time.Duration
+	APICacheHistoricalTTL time.Duration
+	APICacheRedisURL      string
+	// MetricsPort serves expvar metrics (/debug/vars) on an internal-only listener.
+	MetricsPort   string
+	ManagementURL string
+	IP2GeoAddr    string
+	UserAgentAddr string
+	// Sizes and TTLs of the in-process enrichment caches. A size of 0 disables a cache.
+	GeoCacheSize       int
+	GeoCacheTTL        time.Duration
+	UserAgentCacheSize int
+	UserAgentCacheTTL  time.Duration
+	// VisitorIDSecret keys the daily visitor ID hash; it must be the same on every consumer.
+	// It is required unless AppEnv is "development".
+	VisitorIDSecret string
+	AppEnv          string
 }
 
 func Load() *Config {
 	return &Config{
-		ClickHouseAddr:     mustGetEnv("CLICKHOUSE_ADDR"),
-		ClickHouseUser:     getEnv("CLICKHOUSE_USER", "default"),
-		ClickHousePassword: getEnv("CLICKHOUSE_PASSWORD", "default"),
-		ClickHouseDB:       getEnv("CLICKHOUSE_DB", "analytics_db"),
-		KafkaBrokers:       strings.Split(mustGetEnv("KAFKA_BROKERS"), ","),
-		KafkaTopic:         getEnv("KAFKA_TOPIC", "analytics-event"),
-		KafkaGroupID:       getEnv("KAFKA_GROUP_ID", "analytics-group"),
-		APIPort:            getEnv("API_PORT", "8080"),
-		ManagementURL:      mustGetEnv("MANAGEMENT_URL"),
-		IP2GeoAddr:         mustGetEnv("IP2GEO_ADDR"),
-		UserAgentAddr:      mustGetEnv("USER_AGENT_ADDR"),
+		ClickHouseAddr:        mustGetEnv("CLICKHOUSE_ADDR"),
+		ClickHouseUser:        getEnv("CLICKHOUSE_USER", "default"),
+		ClickHousePassword:    getEnv("CLICKHOUSE_PASSWORD", "default"),
+		ClickHouseDB:          getEnv("CLICKHOUSE_DB", "analytics_db"),
+		InsertRetryBackoff:    getEnvDuration("CLICKHOUSE_INSERT_RETRY_BACKOFF", 500*time.Millisecond),
+		InsertDedup:           getEnvBool("CLICKHOUSE_INSERT_DEDUP", true),
+		MaxOpenConns:          getEnvInt("CLICKHOUSE_MAX_OPEN_CONNS", 30),
+		KafkaBrokers:          strings.Split(mustGetEnv("KAFKA_BROKERS"), ","),
+		KafkaTopic:            getEnv("KAFKA_TOPIC", "analytics-event"),
+		KafkaGroupID:          getEnv("KAFKA_GROUP_ID", "analytics-group"),
+		KafkaDLQTopic:         getEnv("KAFKA_DLQ_TOPIC", "analytics-event-dlq"),
+		KafkaDLQReplayGroupID: getEnv("KAFKA_DLQ_REPLAY_GROUP_ID", "analytics-dlq-replay"),
+		EnrichWorkers:         getEnvInt("ENRICH_WORKERS", 32),
+		EnrichQueueSize:       getEnvInt("ENRICH_QUEUE_SIZE", 10000),
+		EnrichBatchSize:       getEnvInt("ENRICH_BATCH_SIZE", 100),
+		APIPort:               getEnv("API_PORT", "8080"),
+		MaxTimelinePoints:     getEnvInt("ANALYTICS_MAX_TIMELINE_POINTS", 5000),
+		QueryTimeout:          getEnvDuration("ANALYTICS_QUERY_TIMEOUT", 30*time.Second),
+		APICacheSize:          getEnvInt("ANALYTICS_CACHE_SIZE", 1000),
+		APICacheLiveTTL:       getEnvDuration("ANALYTICS_CACHE_LIVE_TTL", 30*time.Second),
+		APICacheLiveWindow:    getEnvDuration("ANALYTICS_CACHE_LIVE_WINDOW", 7*24*time.Hour),
+		APICacheHistoricalTTL: getEnvDuration("ANALYTICS_CACHE_HISTORICAL_TTL", 24*time.Hour),
+		APICacheRedisURL:      getEnv("ANALYTICS_CACHE_REDIS_URL", ""),
+		MetricsPort:           getEnv("METRICS_PORT", "9090"),
+		ManagementURL:         mustGetEnv("MANAGEMENT_URL"),
+		IP2GeoAddr:            mustGetEnv("IP2GEO_ADDR"),
+		UserAgentAddr:         mustGetEnv("USER_AGENT_ADDR"),
+		GeoCacheSize:          getEnvInt("IP2GEO_CACHE_SIZE", 100000),
+		GeoCacheTTL:           getEnvDuration("IP2GEO_CACHE_TTL", time.Hour),
+		UserAgentCacheSize:    getEnvInt("USER_AGENT_CACHE_SIZE", 50000),
+		UserAgentCacheTTL:     getEnvDuration("USER_AGENT_CACHE_TTL", 24*time.Hour),
+		VisitorIDSecret:       getEnv("VISITOR_ID_SECRET", ""),
+		AppEnv:                getEnv("APP_ENV", "production"),
 	}
 }
 
@@ -49,3 +114,39 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		panic("environment variable " + key + " must be an integer")
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		panic("environment variable " + key + " must be a boolean")
+	}
+	return b
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic("environment variable " + key + " must be a duration (e.g. 500ms)")
+	}
+	return d
+}
@@ -2,11 +2,18 @@ package db
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
 	"github.com/wintkhantlin/url2short-analytics/internal/config"
 	"github.com/wintkhantlin/url2short-analytics/internal/models"
+	"golang.org/x/sync/errgroup"
 )
 
 func Connect(cfg *config.Config) (clickhouse.Conn, error) {
@@ -20,7 +27,8 @@ func Connect(cfg *config.Config) (clickhouse.Conn, error) {
 		Settings: clickhouse.Settings{
 			"max_execution_time": 60,
 		},
-		DialTimeout: 5 * time.Second,
+		DialTimeout:  5 * time.Second,
+		MaxOpenConns: cfg.MaxOpenConns,
 	})
 	if err != nil {
 		return nil, err
@@ -33,29 +41,70 @@ func Connect(cfg *config.Config) (clickhouse.Conn, error) {
 	return conn, nil
 }
 
+// insertColumns and insertValues must list the same columns in the same order.
+const insertColumns = `code, ip, user_agent, browser, os, device_type, country, state, referer, created_at,
+	browser_version, os_version, device_brand, device_model,
+	country_code, state_code, city, continent, latitude, longitude, accuracy_radius, time_zone, postal_code, asn, as_org,
+	is_bot, bot_category, bot_name, visitor_id`
+
+func insertValues(event models.AnalyticsEvent) []any {
+	return []any{
+		event.Code,
+		event.IP,
+		event.UserAgent,
+		event.Browser,
+		event.OS,
+		event.Device,
+		event.Country,
+		event.State,
+		event.Referer,
+		event.Timestamp,
+		event.BrowserVersion,
+		event.OSVersion,
+		event.DeviceBrand,
+		event.DeviceModel,
+		event.CountryCode,
+		event.StateCode,
+		event.City,
+		event.Continent,
+		event.Latitude,
+		event.Longitude,
+		event.AccuracyRadius,
+		event.TimeZone,
+		event.PostalCode,
+		event.ASN,
+		event.ASOrg,
+		event.IsBot,
+		event.BotCategory,
+		event.BotName,
+		event.VisitorID,
+	}
+}
+
+// WithDedupToken tags inserts made with ctx so ClickHouse drops a retried block with the
+// same token instead of writing it twice.
+func WithDedupToken(ctx context.Context, token string) context.Context {
+	return clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"insert_deduplication_token": token,
+		// Apply the same token to the rollups the materialized views write.
+		"deduplicate_blocks_in_dependent_materialized_views": 1,
+	}))
+}
+
 func Insert(ctx context.Context, conn clickhouse.Conn, event models.AnalyticsEvent) error {
-	return conn.Exec(ctx, `
-		INSERT INTO analytics (code, browser, os, device_type, country, state, referer)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, event.Code, event.Browser, event.OS, event.Device, event.Country, event.State, event.Referer)
+	values := insertValues(event)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+	return conn.Exec(ctx, "INSERT INTO analytics ("+insertColumns+") VALUES ("+placeholders+")", values...)
 }
 
 func InsertBatch(ctx context.Context, conn clickhouse.Conn, events []models.AnalyticsEvent) error {
-	batch, err := conn.PrepareBatch(ctx, "INSERT INTO analytics (code, browser, os, device_type, country, state, referer)")
+	batch, err := conn.PrepareBatch(ctx, "INSERT INTO analytics ("+insertColumns+")")
 	if err != nil {
 		return err
 	}
 	
 	for _, event := range events {
-		err := batch.Append(
-			event.Code,
-			event.Browser,
-			event.OS,
-			event.Device,
-			event.Country,
-			event.State,
-			event.Referer,
-		)
+		err := batch.Append(insertValues(event)...)
 		if err != nil {
 			return err
 		}
@@ -64,113 +113,347 @@ func InsertBatch(ctx context.Context, conn clickhouse.Conn, events []models.Anal
 	return batch.Send()
 }
 
-func GetAnalytics(ctx context.Context, conn clickhouse.Conn, code string, start, end time.Time, interval string) (*models.AnalyticsResponse, error) {
-	var resp models.AnalyticsResponse
+// dataErrorCodes are the ClickHouse exceptions caused by the rows being inserted rather
+// than by the server: values that can't be parsed or converted to their column's type,
+// and columns the table doesn't have.
+var dataErrorCodes = map[int32]bool{
+	6:   true, // CANNOT_PARSE_TEXT
+	8:   true, // THERE_IS_NO_COLUMN
+	10:  true, // NOT_FOUND_COLUMN_IN_BLOCK
+	16:  true, // NO_SUCH_COLUMN_IN_TABLE
+	27:  true, // CANNOT_PARSE_INPUT_ASSERTION_FAILED
+	38:  true, // CANNOT_PARSE_DATE
+	41:  true, // CANNOT_PARSE_DATETIME
+	53:  true, // TYPE_MISMATCH
+	69:  true, // ARGUMENT_OUT_OF_BOUND
+	70:  true, // CANNOT_CONVERT_TYPE
+	72:  true, // CANNOT_PARSE_NUMBER
+	117: true, // INCORRECT_DATA
+	321: true, // VALUE_IS_OUT_OF_RANGE_OF_DATA_TYPE
+	349: true, // CANNOT_INSERT_NULL_IN_ORDINARY_COLUMN
+}
+
+// IsDataError reports whether err, returned by InsertBatch, was caused by the events
+// themselves, so inserting the same events again can't succeed. Connection errors,
+// timeouts and server-side failures are not data errors.
+func IsDataError(err error) bool {
+	// Values the driver couldn't encode for their column.
+	var blockErr *proto.BlockError
+	if errors.As(err, &blockErr) {
+		return true
+	}
+	var exception *clickhouse.Exception
+	return errors.As(err, &exception) && dataErrorCodes[exception.Code]
+}
+
+// filterColumns maps each of models.FilterDimensions to the expression its breakdown
+// groups by.
+var filterColumns = map[string]string{
+	"country":  "country",
+	"state":    "state",
+	"browser":  "browser",
+	"os":       "os",
+	"device":   "device_type",
+	"referrer": "domain(referer)",
+}
+
+// queryCodes returns the aliases q covers.
+func queryCodes(q models.AnalyticsQuery) []string {
+	if q.Codes != nil {
+		return q.Codes
+	}
+	return []string{q.Code}
+}
+
+// whereClause builds the filter shared by every analytics query on the raw table.
+func whereClause(codes []string, start, end time.Time, filters models.AnalyticsFilters) (string, []any) {
+	where, args := codeClause(codes)
+	where += " AND created_at BETWEEN ? AND ?"
+	args = append(args, start, end)
+
+	filter, filterArgs := filterClause(filters, filterColumns)
+	return where + filter, append(args, filterArgs...)
+}
 
-	// Helper to get time function based on interval
-	var timeFunc string
-	switch interval {
+// codeClause selects the rows of codes.
+func codeClause(codes []string) (string, []any) {
+	if len(codes) == 1 {
+		return "code = ?", []any{codes[0]}
+	}
+	return "has(?, code)", []any{codes}
+}
+
+// filterClause turns filters into conditions, each starting with " AND", on the given
+// columns (filterColumns or rollupColumns).
+func filterClause(filters models.AnalyticsFilters, columns map[string]string) (string, []any) {
+	var where string
+	var args []any
+	for _, name := range models.FilterDimensions {
+		dim := filters.Dimension(name)
+		column := columns[name]
+		if len(dim.Include) > 0 {
+			where += " AND has(?, " + column + ")"
+			args = append(args, dim.Include)
+		}
+		if len(dim.Exclude) > 0 {
+			where += " AND NOT has(?, " + column + ")"
+			args = append(args, dim.Exclude)
+		}
+	}
+
+	switch filters.Bots {
+	case models.BotsInclude:
+	case models.BotsOnly:
+		where += " AND is_bot"
+	default:
+		where += " AND NOT is_bot"
+	}
+	return where, args
+}
+
+// bucketExpr returns the expression that rounds column down to its timeline bucket for q,
+// and the arguments for its placeholders; columnArgs are the column's own (for a "?").
+// The time zone is passed to every date function so that day, week and month
+// boundaries fall on the viewer's midnight.
+func bucketExpr(q models.AnalyticsQuery, column string, columnArgs ...any) (string, []any) {
+	args := slices.Clone(columnArgs)
+	tz := func() string {
+		if q.TimeZone == "" {
+			return ""
+		}
+		args = append(args, q.TimeZone)
+		return ", ?"
+	}
+
+	switch q.Interval {
 	case "minute":
-		timeFunc = "toStartOfMinute"
-	case "hour":
-		timeFunc = "toStartOfHour"
+		return "toStartOfMinute(" + column + tz() + ")", args
 	case "day":
-		timeFunc = "toStartOfDay"
-	case "week":
-		timeFunc = "toStartOfWeek"
-	case "month":
-		timeFunc = "toStartOfMonth"
-	case "year":
-		timeFunc = "toStartOfYear"
+		return "toStartOfDay(" + column + tz() + ")", args
+	case "week", "month", "year":
+		// These return a Date; turn it back into that midnight in the same zone.
+		var inner string
+		switch q.Interval {
+		case "week":
+			// Mode 0 starts weeks on Sunday, mode 1 on Monday.
+			mode := "0"
+			if q.WeekStart == models.WeekStartMonday {
+				mode = "1"
+			}
+			inner = "toStartOfWeek(" + column + ", " + mode + tz() + ")"
+		case "month":
+			inner = "toStartOfMonth(" + column + tz() + ")"
+		default:
+			inner = "toStartOfYear(" + column + tz() + ")"
+		}
+		return "toDateTime(" + inner + tz() + ")", args
 	default:
-		timeFunc = "toStartOfHour"
+		return "toStartOfHour(" + column + tz() + ")", args
 	}
+}
 
-	// 1. Total Clicks (within range)
-	err := conn.QueryRow(ctx, "SELECT count() FROM analytics WHERE code = ? AND created_at BETWEEN ? AND ?", code, start, end).Scan(&resp.TotalClicks)
-	if err != nil {
-		return nil, err
+// fillStep is the WITH FILL step for each interval.
+var fillStep = map[string]string{
+	"minute": "INTERVAL 1 MINUTE",
+	"hour":   "INTERVAL 1 HOUR",
+	"day":    "INTERVAL 1 DAY",
+	"week":   "INTERVAL 1 WEEK",
+	"month":  "INTERVAL 1 MONTH",
+	"year":   "INTERVAL 1 YEAR",
+}
+
+// breakdownClause narrows the breakdown for dimension name to q.Breakdowns[name], if set.
+// It returns the extra condition on columns[name] and args extended with its placeholder
+// value.
+func breakdownClause(q models.AnalyticsQuery, columns map[string]string, name string, args []any) (string, []any) {
+	names, ok := q.Breakdowns[name]
+	if !ok {
+		return "", args
+	}
+	if len(names) == 0 {
+		return " AND 0", args
 	}
+	return " AND has(?, " + columns[name] + ")", append(slices.Clone(args), names)
+}
 
-	// 2. Timeline
-	query := `
-		SELECT ` + timeFunc + `(created_at) as time, count() as count 
-		FROM analytics 
-		WHERE code = ? AND created_at BETWEEN ? AND ?
-		GROUP BY time ORDER BY time
-	`
-	err = conn.Select(ctx, &resp.Timeline, query, code, start, end)
-	if err != nil {
-		return nil, err
+// visitorsExpr approximates distinct visitors. Rows from before visitor IDs existed
+// have visitor_id 0 and are not counted.
+const visitorsExpr = "uniqCombinedIf(visitor_id, visitor_id != 0)"
+
+// GetAnalytics aggregates clicks for q.Code between q.Start and q.End, narrowed by
+// q.Filters. Bot traffic is left out unless q.Filters.Bots says otherwise. Long ranges
+// are read from the rollups (see plan). The queries run concurrently; the first to fail
+// cancels the rest.
+func GetAnalytics(ctx context.Context, conn clickhouse.Conn, q models.AnalyticsQuery) (*models.AnalyticsResponse, error) {
+	var resp models.AnalyticsResponse
+
+	// Every query shares the same source and filter; the timeline may need a finer one.
+	src := newSource(q, false)
+	selectCounts := src.clicks + " as count, " + src.visitors + " as visitors"
+
+	// breakdown selects the top rows for one dimension; extra narrows the rows further.
+	breakdown := func(dest *[]models.DimensionSummary, name, extra, tail string) func(context.Context) error {
+		return func(ctx context.Context) error {
+			cond, condArgs := breakdownClause(q, src.columns, name, src.args)
+			return conn.Select(ctx, dest, `
+				SELECT `+src.columns[name]+` as name, `+selectCounts+`
+				FROM `+src.from+`
+				WHERE `+src.where+cond+extra+`
+				GROUP BY name
+				ORDER BY count DESC`+tail+`
+			`, condArgs...)
+		}
 	}
 
-	// 3. Browsers
-	err = conn.Select(ctx, &resp.Browsers, `
-		SELECT browser as name, count() as count 
-		FROM analytics WHERE code = ? AND created_at BETWEEN ? AND ? GROUP BY name ORDER BY count DESC LIMIT 10
-	`, code, start, end)
-	if err != nil {
-		return nil, err
+	queries := []struct {
+		name string
+		run  func(context.Context) error
+	}{
+		// Total clicks and unique visitors (within range)
+		{"total", func(ctx context.Context) error {
+			return conn.QueryRow(ctx, "SELECT "+selectCounts+" FROM "+src.from+" WHERE "+src.where, src.args...).Scan(&resp.TotalClicks, &resp.UniqueVisitors)
+		}},
+		// Timeline, one row per bucket from start to end with zeros for empty buckets
+		{"timeline", func(ctx context.Context) error {
+			tsrc := newSource(q, true)
+			bucket, bucketArgs := bucketExpr(q, tsrc.time)
+			from, fromArgs := bucketExpr(q, "?", q.Start)
+			to, toArgs := bucketExpr(q, "?", q.End)
+			step, ok := fillStep[q.Interval]
+			if !ok {
+				step = fillStep["hour"]
+			}
+			query := `
+				SELECT ` + bucket + ` as time, ` + tsrc.clicks + ` as count, ` + tsrc.visitors + ` as visitors
+				FROM ` + tsrc.from + `
+				WHERE ` + tsrc.where + `
+				GROUP BY time
+				ORDER BY time WITH FILL FROM ` + from + ` TO ` + to + ` + ` + step + ` STEP ` + step + `
+			`
+			timelineArgs := append(append(append(bucketArgs, tsrc.args...), fromArgs...), toArgs...)
+			return conn.Select(ctx, &resp.Timeline, query, timelineArgs...)
+		}},
+		{"browsers", breakdown(&resp.Browsers, "browser", "", " LIMIT 10")},
+		{"os", breakdown(&resp.OS, "os", "", " LIMIT 10")},
+		// device_type holds the class computed at ingest; there are few, so all are listed.
+		{"devices", breakdown(&resp.Devices, "device", "", "")},
+		{"countries", breakdown(&resp.Countries, "country", "", " LIMIT 10")},
+		{"referrers", breakdown(&resp.Referrers, "referrer", " AND "+src.referrerCond, " LIMIT 10")},
 	}
 
-	// 4. OS
-	err = conn.Select(ctx, &resp.OS, `
-		SELECT os as name, count() as count 
-		FROM analytics WHERE code = ? AND created_at BETWEEN ? AND ? GROUP BY name ORDER BY count DESC LIMIT 10
-	`, code, start, end)
+	// Each query writes its own fields of resp and its own slot of durations.
+	durations := make([]any, 0, 2*len(queries))
+	for _, query := range queries {
+		durations = append(durations, query.name, time.Duration(0))
+	}
+	started := time.Now()
+	g, gctx := errgroup.WithContext(ctx)
+	for i, query := range queries {
+		g.Go(func() error {
+			queryStarted := time.Now()
+			err := query.run(gctx)
+			durations[2*i+1] = time.Since(queryStarted)
+			if err != nil {
+				return fmt.Errorf("%s query: %w", query.name, err)
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+
+	slog.Info("Analytics queries",
+		"code", q.Code,
+		"codes", len(q.Codes),
+		"rollups", src.from != "analytics",
+		"elapsed", time.Since(started),
+		slog.Group("queries", durations...),
+		"error", err,
+	)
 	if err != nil {
 		return nil, err
 	}
+	return &resp, nil
+}
 
-	// 5. Devices
-	err = conn.Select(ctx, &resp.Devices, `
-		SELECT
-			multiIf(device_type IN ('phone','mobile','iphone','android','ipad','tablet'), 'mobile', 'desktop') as name,
-			count() as count
-		FROM analytics
-		WHERE code = ? AND created_at BETWEEN ? AND ?
-		GROUP BY name
-		ORDER BY count DESC
-	`, code, start, end)
+// GetLeaderboard ranks the aliases in q.Codes by clicks under the same filters as
+// GetAnalytics. Aliases without clicks in the range are listed last with zero counts.
+func GetLeaderboard(ctx context.Context, conn clickhouse.Conn, q models.AnalyticsQuery) ([]models.AliasSummary, error) {
+	src := newSource(q, false)
+
+	var rows []models.AliasSummary
+	err := conn.Select(ctx, &rows, `
+		SELECT code, `+src.clicks+` as count, `+src.visitors+` as visitors
+		FROM `+src.from+` WHERE `+src.where+` GROUP BY code ORDER BY count DESC, code
+	`, src.args...)
 	if err != nil {
 		return nil, err
 	}
+	return fillLeaderboard(rows, queryCodes(q)), nil
+}
 
-	// Always return both buckets for a stable UI.
-	deviceCounts := map[string]uint64{}
-	for _, item := range resp.Devices {
-		deviceCounts[item.Name] = item.Count
+// fillLeaderboard appends a zero row, in code order, for every code missing from rows.
+func fillLeaderboard(rows []models.AliasSummary, codes []string) []models.AliasSummary {
+	seen := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		seen[row.Code] = true
 	}
-	resp.Devices = []models.DimensionSummary{
-		{Name: "mobile", Count: deviceCounts["mobile"]},
-		{Name: "desktop", Count: deviceCounts["desktop"]},
+	var missing []string
+	for _, code := range codes {
+		if !seen[code] {
+			seen[code] = true
+			missing = append(missing, code)
+		}
 	}
+	slices.Sort(missing)
+	for _, code := range missing {
+		rows = append(rows, models.AliasSummary{Code: code})
+	}
+	return rows
+}
 
-	// 6. Countries
-	err = conn.Select(ctx, &resp.Countries, `
-		SELECT country as name, count() as count 
-		FROM analytics WHERE code = ? AND created_at BETWEEN ? AND ? GROUP BY name ORDER BY count DESC LIMIT 10
-	`, code, start, end)
+// ExportEvents streams the click events of q.Code between q.Start and q.End, oldest
+// first and narrowed by q.Filters, to fn one row at a time. ClickHouse sends the result
+// in blocks, so memory use does not grow with the size of the export.
+func ExportEvents(ctx context.Context, conn clickhouse.Conn, q models.AnalyticsQuery, fn func(models.ExportRow) error) error {
+	where, args := whereClause(queryCodes(q), q.Start, q.End, q.Filters)
+
+	rows, err := conn.Query(ctx, `
+		SELECT created_at, country, state, browser, os, device_type, referer
+		FROM analytics WHERE `+where+` ORDER BY created_at
+	`, args...)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.ExportRow
+		if err := rows.ScanStruct(&row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
 	}
+	return rows.Err()
+}
 
-	// 7. Referrers
-	err = conn.Select(ctx, &resp.Referrers, `
-		SELECT domain(referer) as name, count() as count 
-		FROM analytics 
-		WHERE code = ?
-		  AND created_at BETWEEN ? AND ?
-		  AND referer != ''
-		  AND lowerUTF8(trim(referer)) NOT IN ('null','-','(null)','about:blank')
-		  AND domain(referer) != ''
-		GROUP BY name
-		ORDER BY count DESC
-		LIMIT 10
-	`, code, start, end)
+// TimeZones lists the zones the server can convert to. Its tz database is its own and
+// may differ from Go's.
+func TimeZones(ctx context.Context, conn clickhouse.Conn) ([]string, error) {
+	rows, err := conn.Query(ctx, `SELECT time_zone FROM system.time_zones`)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &resp, nil
+	var zones []string
+	for rows.Next() {
+		var zone string
+		if err := rows.Scan(&zone); err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	return zones, rows.Err()
 }
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+func TestWhereClause(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	where, args := whereClause([]string{"abc"}, start, end, models.AnalyticsFilters{
+		Country: models.DimensionFilter{Include: []string{"germany"}},
+		Device:  models.DimensionFilter{Include: []string{"mobile"}, Exclude: []string{"tablet"}},
+		Bots:    models.BotsOnly,
+	})
+
+	assert.Equal(t, "code = ? AND created_at BETWEEN ? AND ?"+
+		" AND has(?, country)"+
+		" AND has(?, device_type) AND NOT has(?, device_type)"+
+		" AND is_bot", where)
+	assert.Equal(t, []any{"abc", start, end, []string{"germany"}, []string{"mobile"}, []string{"tablet"}}, args)
+}
+
+func TestWhereClause_MultipleCodes(t *testing.T) {
+	where, args := whereClause([]string{"abc", "def"}, time.Time{}, time.Time{}, models.AnalyticsFilters{})
+	assert.Equal(t, "has(?, code) AND created_at BETWEEN ? AND ? AND NOT is_bot", where)
+	assert.Equal(t, []string{"abc", "def"}, args[0])
+}
+
+func TestWhereClause_ExcludesBotsByDefault(t *testing.T) {
+	where, _ := whereClause([]string{"abc"}, time.Time{}, time.Time{}, models.AnalyticsFilters{})
+	assert.Equal(t, "code = ? AND created_at BETWEEN ? AND ? AND NOT is_bot", where)
+}
+
+func TestBucketExpr(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    models.AnalyticsQuery
+		wantExpr string
+		wantArgs []any
+	}{
+		{
+			name:     "server zone",
+			query:    models.AnalyticsQuery{Interval: "day"},
+			wantExpr: "toStartOfDay(created_at)",
+		},
+		{
+			name:     "hour in zone",
+			query:    models.AnalyticsQuery{Interval: "hour", TimeZone: "Asia/Yangon"},
+			wantExpr: "toStartOfHour(created_at, ?)",
+			wantArgs: []any{"Asia/Yangon"},
+		},
+		{
+			name:     "sunday weeks",
+			query:    models.AnalyticsQuery{Interval: "week", WeekStart: models.WeekStartSunday},
+			wantExpr: "toDateTime(toStartOfWeek(created_at, 0))",
+		},
+		{
+			name:     "iso weeks in zone",
+			query:    models.AnalyticsQuery{Interval: "week", TimeZone: "Asia/Yangon", WeekStart: models.WeekStartMonday},
+			wantExpr: "toDateTime(toStartOfWeek(created_at, 1, ?), ?)",
+			wantArgs: []any{"Asia/Yangon", "Asia/Yangon"},
+		},
+		{
+			name:     "month in zone",
+			query:    models.AnalyticsQuery{Interval: "month", TimeZone: "Europe/Berlin"},
+			wantExpr: "toDateTime(toStartOfMonth(created_at, ?), ?)",
+			wantArgs: []any{"Europe/Berlin", "Europe/Berlin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, args := bucketExpr(tt.query, "created_at")
+			assert.Equal(t, tt.wantExpr, expr)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestBucketExpr_Placeholder(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	expr, args := bucketExpr(models.AnalyticsQuery{Interval: "month", TimeZone: "Asia/Yangon"}, "?", start)
+	assert.Equal(t, "toDateTime(toStartOfMonth(?, ?), ?)", expr)
+	assert.Equal(t, []any{start, "Asia/Yangon", "Asia/Yangon"}, args)
+}
+
+func TestBreakdownClause(t *testing.T) {
+	args := []any{"abc"}
+
+	cond, got := breakdownClause(models.AnalyticsQuery{}, filterColumns, "country", args)
+	assert.Empty(t, cond)
+	assert.Equal(t, args, got)
+
+	q := models.AnalyticsQuery{Breakdowns: map[string][]string{"referrer": {"google.com"}, "os": {}}}
+	cond, got = breakdownClause(q, filterColumns, "referrer", args)
+	assert.Equal(t, " AND has(?, domain(referer))", cond)
+	assert.Equal(t, []any{"abc", []string{"google.com"}}, got)
+	assert.Equal(t, []any{"abc"}, args, "args is not modified")
+
+	cond, _ = breakdownClause(q, filterColumns, "os", args)
+	assert.Equal(t, " AND 0", cond)
+}
+
+func TestFillLeaderboard(t *testing.T) {
+	rows := []models.AliasSummary{{Code: "b", Count: 5, Visitors: 3}, {Code: "a", Count: 2, Visitors: 2}}
+	got := fillLeaderboard(rows, []string{"a", "d", "b", "c"})
+	assert.Equal(t, []models.AliasSummary{
+		{Code: "b", Count: 5, Visitors: 3},
+		{Code: "a", Count: 2, Visitors: 2},
+		{Code: "c"},
+		{Code: "d"},
+	}, got)
+}
+
+func TestIsDataError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"value not convertible", &proto.BlockError{Op: "AppendRow", ColumnName: "created_at", Err: &column.ColumnConverterError{Op: "AppendRow", From: "string", To: "DateTime"}}, true},
+		{"type mismatch", &clickhouse.Exception{Code: 53, Name: "DB::Exception"}, true},
+		{"unknown column", fmt.Errorf("prepare batch: %w", &clickhouse.Exception{Code: 16}), true},
+		{"too many parts", &clickhouse.Exception{Code: 252}, false},
+		{"memory limit", &clickhouse.Exception{Code: 241}, false},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, false},
+		{"timeout", context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsDataError(tt.err))
+		})
+	}
+}
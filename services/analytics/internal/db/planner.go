@@ -0,0 +1,196 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+// Rollup tables, filled by materialized views over analytics (see
+// migrations/clickhouse/20250318_09.sql). Rows are pre-aggregated per alias, UTC hour or
+// day, and every dimension the API filters or breaks down by.
+const (
+	hourlyRollup = "analytics_hourly"
+	dailyRollup  = "analytics_daily"
+)
+
+// minRollupRange is the shortest range read from the rollups. Shorter ranges touch few
+// raw rows, and most of them would fall in partial hours read from the raw table anyway.
+const minRollupRange = 6 * time.Hour
+
+// refererValid is the condition under which a raw referer counts towards the referrers
+// breakdown. The rollups store it as has_referrer.
+const refererValid = "referer != '' AND lowerUTF8(trim(referer)) NOT IN ('null','-','(null)','about:blank')"
+
+// rollupColumns maps each of models.FilterDimensions to its rollup column.
+var rollupColumns = map[string]string{
+	"country":  "country",
+	"state":    "state",
+	"browser":  "browser",
+	"os":       "os",
+	"device":   "device_type",
+	"referrer": "referrer",
+}
+
+// source is what an analytics query reads from: the raw table, or the rollups stitched
+// together with the raw rows at the edges of the range. Both give the same numbers.
+type source struct {
+	// from is a table or subquery, and where the condition that selects q's rows in it.
+	from  string
+	where string
+	// args holds the values for the placeholders in from, then in where.
+	args []any
+	// time is the column the timeline is bucketed on.
+	time     string
+	clicks   string
+	visitors string
+	// columns maps each of models.FilterDimensions to its column in from.
+	columns map[string]string
+	// referrerCond keeps the rows with a usable referrer.
+	referrerCond string
+}
+
+// segment is a part of a query range read from one table; start is inclusive and end
+// exclusive.
+type segment struct {
+	table      string
+	start, end time.Time
+}
+
+// newSource picks where q is read from. timeline says whether the rows will be bucketed
+// by q.Interval, which rules out rollups whose periods don't line up with the buckets.
+func newSource(q models.AnalyticsQuery, timeline bool) source {
+	segments := plan(q, timeline)
+	if segments == nil {
+		where, args := whereClause(queryCodes(q), q.Start, q.End, q.Filters)
+		return source{
+			from:         "analytics",
+			where:        where,
+			args:         args,
+			time:         "created_at",
+			clicks:       "count()",
+			visitors:     visitorsExpr,
+			columns:      filterColumns,
+			referrerCond: refererValid + " AND domain(referer) != ''",
+		}
+	}
+
+	var parts []string
+	var args []any
+	for _, seg := range segments {
+		part, partArgs := segmentQuery(queryCodes(q), seg)
+		parts = append(parts, part)
+		args = append(args, partArgs...)
+	}
+	filter, filterArgs := filterClause(q.Filters, rollupColumns)
+	return source{
+		from:         "(" + strings.Join(parts, " UNION ALL ") + ")",
+		where:        "1" + filter,
+		args:         append(args, filterArgs...),
+		time:         "period",
+		clicks:       "sum(clicks)",
+		visitors:     "uniqCombinedIfMerge(visitors)",
+		columns:      rollupColumns,
+		referrerCond: "has_referrer AND referrer != ''",
+	}
+}
+
+// segmentQuery selects the rollup-shaped rows of seg for codes. Raw segments are
+// aggregated on the fly into the same columns.
+func segmentQuery(codes []string, seg segment) (string, []any) {
+	cond, args := codeClause(codes)
+	if seg.table == "analytics" {
+		return `SELECT code, created_at AS period, browser, os, device_type, country, state,
+			domain(referer) AS referrer, ` + refererValid + ` AS has_referrer, is_bot,
+			count() AS clicks, uniqCombinedIfState(visitor_id, visitor_id != 0) AS visitors
+		FROM analytics WHERE ` + cond + ` AND created_at >= ? AND created_at < ?
+		GROUP BY code, period, browser, os, device_type, country, state, referrer, has_referrer, is_bot`,
+			append(args, seg.start, seg.end)
+	}
+	return `SELECT code, period, browser, os, device_type, country, state, referrer, has_referrer, is_bot, clicks, visitors
+		FROM ` + seg.table + ` WHERE ` + cond + ` AND period >= ? AND period < ?`,
+		append(args, seg.start, seg.end)
+}
+
+// plan splits q's range into raw, hourly and daily segments: whole UTC days from the
+// daily rollup, the whole hours around them from the hourly one and the partial hours at
+// either end from the raw table. It returns nil when the whole query should be read from
+// the raw table: minute intervals, short ranges, and timelines in zones whose hours or
+// days don't line up with the rollups' UTC periods.
+func plan(q models.AnalyticsQuery, timeline bool) []segment {
+	// created_at has whole seconds and the range is inclusive.
+	start := q.Start.Truncate(time.Second)
+	end := q.End.Truncate(time.Second).Add(time.Second)
+	if q.Interval == "minute" || end.Sub(start) < minRollupRange {
+		return nil
+	}
+
+	useDaily := true
+	if timeline {
+		wholeHours, utc := zoneOffsets(q.TimeZone, start, end)
+		if !wholeHours {
+			return nil
+		}
+		useDaily = utc && q.Interval != "hour"
+	}
+
+	hourStart, hourEnd := ceilTime(start, time.Hour), end.Truncate(time.Hour)
+	if !hourStart.Before(hourEnd) {
+		return nil
+	}
+
+	var segments []segment
+	add := func(table string, from, to time.Time) {
+		if from.Before(to) {
+			segments = append(segments, segment{table, from, to})
+		}
+	}
+	add("analytics", start, hourStart)
+	dayStart, dayEnd := ceilTime(hourStart, 24*time.Hour), hourEnd.Truncate(24*time.Hour)
+	if useDaily && dayStart.Before(dayEnd) {
+		add(hourlyRollup, hourStart, dayStart)
+		add(dailyRollup, dayStart, dayEnd)
+		add(hourlyRollup, dayEnd, hourEnd)
+	} else {
+		add(hourlyRollup, hourStart, hourEnd)
+	}
+	add("analytics", hourEnd, end)
+	return segments
+}
+
+// ceilTime rounds t up to a multiple of d since the zero time; for hours and days that
+// is a UTC hour or midnight.
+func ceilTime(t time.Time, d time.Duration) time.Time {
+	if r := t.Truncate(d); r.Before(t) {
+		return r.Add(d)
+	}
+	return t
+}
+
+// zoneOffsets reports whether the zone named tz stays a whole number of hours from UTC
+// between start and end, and whether it stays at UTC. The offset is sampled daily, which
+// catches every daylight saving period. The ClickHouse server's zone (tz == "") is
+// assumed to be whole hours but not UTC.
+func zoneOffsets(tz string, start, end time.Time) (wholeHours, utc bool) {
+	if tz == "" {
+		return true, false
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return false, false
+	}
+
+	wholeHours, utc = true, true
+	for t := start; ; t = t.Add(24 * time.Hour) {
+		if t.After(end) {
+			t = end
+		}
+		_, offset := t.In(loc).Zone()
+		wholeHours = wholeHours && offset%3600 == 0
+		utc = utc && offset == 0
+		if !t.Before(end) {
+			return wholeHours, utc
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+func TestPlan(t *testing.T) {
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2025, 3, day, hour, min, 0, 0, time.UTC)
+	}
+	// A query's end is inclusive; segments end exclusively a second later.
+	end := at(4, 15, 20).Add(-time.Second)
+
+	tests := []struct {
+		name     string
+		query    models.AnalyticsQuery
+		timeline bool
+		want     []segment
+	}{
+		{
+			name:  "short range",
+			query: models.AnalyticsQuery{Start: at(4, 10, 20), End: end, Interval: "hour"},
+		},
+		{
+			name:  "minute interval",
+			query: models.AnalyticsQuery{Start: at(1, 10, 20), End: end, Interval: "minute"},
+		},
+		{
+			name:  "days, hours and raw edges",
+			query: models.AnalyticsQuery{Start: at(1, 10, 20), End: end, Interval: "hour"},
+			want: []segment{
+				{"analytics", at(1, 10, 20), at(1, 11, 0)},
+				{hourlyRollup, at(1, 11, 0), at(2, 0, 0)},
+				{dailyRollup, at(2, 0, 0), at(4, 0, 0)},
+				{hourlyRollup, at(4, 0, 0), at(4, 15, 0)},
+				{"analytics", at(4, 15, 0), at(4, 15, 20)},
+			},
+		},
+		{
+			name:     "hourly timeline can't use days",
+			query:    models.AnalyticsQuery{Start: at(1, 10, 20), End: end, Interval: "hour", TimeZone: "UTC"},
+			timeline: true,
+			want: []segment{
+				{"analytics", at(1, 10, 20), at(1, 11, 0)},
+				{hourlyRollup, at(1, 11, 0), at(4, 15, 0)},
+				{"analytics", at(4, 15, 0), at(4, 15, 20)},
+			},
+		},
+		{
+			name:     "daily timeline in utc",
+			query:    models.AnalyticsQuery{Start: at(1, 0, 0), End: at(5, 0, 0).Add(-time.Second), Interval: "day", TimeZone: "UTC"},
+			timeline: true,
+			want:     []segment{{dailyRollup, at(1, 0, 0), at(5, 0, 0)}},
+		},
+		{
+			name:     "daily timeline in another zone",
+			query:    models.AnalyticsQuery{Start: at(1, 0, 0), End: at(5, 0, 0).Add(-time.Second), Interval: "day", TimeZone: "Asia/Bangkok"},
+			timeline: true,
+			want:     []segment{{hourlyRollup, at(1, 0, 0), at(5, 0, 0)}},
+		},
+		{
+			name:     "half-hour zone",
+			query:    models.AnalyticsQuery{Start: at(1, 0, 0), End: end, Interval: "day", TimeZone: "Asia/Yangon"},
+			timeline: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, plan(tt.query, tt.timeline))
+		})
+	}
+}
+
+func TestZoneOffsets(t *testing.T) {
+	winter := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	summer := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	wholeHours, utc := zoneOffsets("Europe/London", winter, winter.Add(48*time.Hour))
+	assert.True(t, wholeHours)
+	assert.True(t, utc)
+
+	// British Summer Time is UTC+1 somewhere in the middle of the range.
+	wholeHours, utc = zoneOffsets("Europe/London", winter, summer.AddDate(0, 3, 0))
+	assert.True(t, wholeHours)
+	assert.False(t, utc)
+
+	wholeHours, _ = zoneOffsets("Australia/Adelaide", winter, summer)
+	assert.False(t, wholeHours)
+}
+
+func TestNewSource_Rollup(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	q := models.AnalyticsQuery{
+		Code:     "abc",
+		Start:    start,
+		End:      start.Add(12*time.Hour - time.Second),
+		Interval: "hour",
+		Filters:  models.AnalyticsFilters{Referrer: models.DimensionFilter{Include: []string{"google.com"}}},
+	}
+
+	src := newSource(q, false)
+	assert.Equal(t, "(SELECT code, period, browser, os, device_type, country, state, referrer, has_referrer, is_bot, clicks, visitors\n"+
+		"\t\tFROM analytics_hourly WHERE code = ? AND period >= ? AND period < ?)", src.from)
+	assert.Equal(t, "1 AND has(?, referrer) AND NOT is_bot", src.where)
+	assert.Equal(t, []any{"abc", start, start.Add(12 * time.Hour), []string{"google.com"}}, src.args)
+	assert.Equal(t, "sum(clicks)", src.clicks)
+
+	q.End = start.Add(time.Hour)
+	assert.Equal(t, "analytics", newSource(q, false).from)
+}
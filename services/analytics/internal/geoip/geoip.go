@@ -9,20 +9,26 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/wintkhantlin/url2short-analytics/internal/cache"
 	pb "github.com/wintkhantlin/url2short-ip2geo/gen"
 )
 
 var (
-	client pb.Ip2GeoServiceClient
-	conn   *grpc.ClientConn
-	once   sync.Once
+	client   pb.Ip2GeoServiceClient
+	conn     *grpc.ClientConn
+	once     sync.Once
+	geoCache *cache.LRU[string, Location]
 )
 
-// Init initializes the GeoIP gRPC client.
-func Init(addr string) error {
+// Init initializes the GeoIP gRPC client. Successful lookups are cached for cacheTTL in
+// an LRU of cacheSize entries; a cacheSize of 0 disables caching.
+func Init(addr string, cacheSize int, cacheTTL time.Duration) error {
 	var err error
 	once.Do(func() {
-		slog.Info("Connecting to IP2Geo service", "addr", addr)
+		geoCache = cache.New[string, Location](cacheSize, cacheTTL)
+		cache.Publish("geoip_cache", func() cache.Stats { return geoCache.Stats() })
+
+		slog.Info("Connecting to IP2Geo service", "addr", addr, "cache_size", cacheSize, "cache_ttl", cacheTTL)
 
 		// Create a connection to the server
 		conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -36,16 +42,69 @@ func Init(addr string) error {
 	return err
 }
 
-// GetLocation returns the country and state for a given IP address.
-// Returns "unknown", "unknown" if the IP is invalid or lookup fails.
-// Returns "internal", "internal" for localhost/internal IPs.
-func GetLocation(ip string) (string, string) {
-	if ip == "127.0.0.1" || ip == "localhost" || ip == "" {
-		return "internal", "internal"
+// Location is the geo information resolved for an IP address. ASN and Organization are
+// only set when the IP2Geo service has an ASN database loaded.
+type Location struct {
+	Country            string
+	State              string
+	CountryISOCode     string
+	SubdivisionISOCode string
+	City               string
+	Continent          string
+	Latitude           float64
+	Longitude          float64
+	AccuracyRadius     uint16
+	TimeZone           string
+	PostalCode         string
+	ASN                uint32
+	Organization       string
+}
+
+func fromResponse(resp *pb.GeoResponse) Location {
+	return Location{
+		Country:            resp.Country,
+		State:              resp.State,
+		CountryISOCode:     resp.CountryIsoCode,
+		SubdivisionISOCode: resp.SubdivisionIsoCode,
+		City:               resp.City,
+		Continent:          resp.Continent,
+		Latitude:           resp.Latitude,
+		Longitude:          resp.Longitude,
+		AccuracyRadius:     uint16(resp.AccuracyRadius),
+		TimeZone:           resp.TimeZone,
+		PostalCode:         resp.PostalCode,
+		ASN:                resp.Asn,
+		Organization:       resp.Organization,
+	}
+}
+
+var (
+	unknownLocation  = Location{Country: "unknown", State: "unknown"}
+	internalLocation = Location{Country: "internal", State: "internal"}
+)
+
+// isInternal reports whether ip is "internal" without asking the IP2Geo service: a
+// missing IP, or "localhost", which some proxies send instead of an address and the
+// service would reject as invalid.
+func isInternal(ip string) bool {
+	return ip == "" || ip == "localhost"
+}
+
+// GetLocation returns the location for a given IP address.
+// Country and State are "unknown" if the IP is invalid or lookup fails.
+// The IP2Geo service reports private, loopback, link-local and CGNAT addresses
+// as "internal"; a missing IP and "localhost" are treated the same way.
+func GetLocation(ip string) Location {
+	if isInternal(ip) {
+		return internalLocation
 	}
 
 	if client == nil {
-		return "unknown", "unknown"
+		return unknownLocation
+	}
+
+	if location, ok := geoCache.Get(ip); ok {
+		return location
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -55,10 +114,65 @@ func GetLocation(ip string) (string, string) {
 	if err != nil {
 		// Log error but don't fail the request, just return unknown
 		slog.Debug("Failed to lookup IP", "ip", ip, "error", err)
-		return "unknown", "unknown"
+		return unknownLocation
+	}
+
+	location := fromResponse(resp)
+	geoCache.Add(ip, location)
+	return location
+}
+
+// GetLocations resolves many IPs with a single BatchLookup call. Results are in the same
+// order as ips and follow the same fallbacks as GetLocation.
+func GetLocations(ips []string) []Location {
+	locations := make([]Location, len(ips))
+
+	// Answer from the cache where possible and send each remaining address only once,
+	// remembering every position it appeared at.
+	var req pb.IpBatchRequest
+	positions := map[string][]int{}
+	for i, ip := range ips {
+		if isInternal(ip) {
+			locations[i] = internalLocation
+			continue
+		}
+		locations[i] = unknownLocation
+		if location, ok := geoCache.Get(ip); ok {
+			locations[i] = location
+			continue
+		}
+		if _, queued := positions[ip]; !queued {
+			req.Requests = append(req.Requests, &pb.IpRequest{Ip: ip})
+		}
+		positions[ip] = append(positions[ip], i)
+	}
+
+	if len(req.Requests) == 0 || client == nil {
+		return locations
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.BatchLookup(ctx, &req)
+	if err != nil {
+		slog.Debug("Failed to batch lookup IPs", "count", len(req.Requests), "error", err)
+		return locations
+	}
+
+	for i, result := range resp.Results {
+		if i >= len(req.Requests) {
+			break
+		}
+		ip := req.Requests[i].Ip
+		location := fromResponse(result)
+		geoCache.Add(ip, location)
+		for _, pos := range positions[ip] {
+			locations[pos] = location
+		}
 	}
 
-	return resp.Country, resp.State
+	return locations
 }
 
 // Close closes the gRPC connection.
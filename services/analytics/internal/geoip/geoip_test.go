@@ -0,0 +1,33 @@
+package geoip
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLocation_WithoutService(t *testing.T) {
+	// Init is never called in tests, so only addresses that need no lookup resolve.
+	tests := []struct {
+		ip   string
+		want Location
+	}{
+		{"", internalLocation},
+		{"localhost", internalLocation},
+		{"203.0.113.7", unknownLocation},
+		{"not-an-ip", unknownLocation},
+	}
+
+	ips := make([]string, 0, len(tests))
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, GetLocation(tt.ip))
+		})
+		ips = append(ips, tt.ip)
+	}
+
+	locations := GetLocations(ips)
+	for i, tt := range tests {
+		assert.Equal(t, tt.want, locations[i], "GetLocations(%q)", tt.ip)
+	}
+}
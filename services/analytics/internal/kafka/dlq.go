@@ -0,0 +1,287 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/wintkhantlin/url2short-analytics/internal/config"
+)
+
+// Headers added to every dead-lettered message so it can be traced back to its source.
+const (
+	HeaderDLQReason    = "x-dlq-reason"
+	HeaderDLQTopic     = "x-dlq-original-topic"
+	HeaderDLQPartition = "x-dlq-original-partition"
+	HeaderDLQOffset    = "x-dlq-original-offset"
+
+	headerDLQPrefix = "x-dlq-"
+)
+
+// DeadLetterWriter republishes rejected analytics events to the configured DLQ topic.
+// A nil writer drops messages after logging them.
+type DeadLetterWriter struct {
+	writer *kafka.Writer
+}
+
+// NewDeadLetterWriter returns nil when no DLQ topic is configured.
+func NewDeadLetterWriter(cfg *config.Config) *DeadLetterWriter {
+	if cfg.KafkaDLQTopic == "" {
+		return nil
+	}
+
+	return &DeadLetterWriter{
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(cfg.KafkaBrokers...),
+			Topic:                  cfg.KafkaDLQTopic,
+			Balancer:               &kafka.Hash{},
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+// Publish sends the original messages to the DLQ topic along with the rejection reason
+// and their source topic, partition and offset. Original headers are preserved.
+func (d *DeadLetterWriter) Publish(ctx context.Context, reason string, msgs ...kafka.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	if d == nil {
+		slog.Warn("Dropping rejected events (no DLQ topic configured)", "count", len(msgs), "reason", reason)
+		return nil
+	}
+
+	out := make([]kafka.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		out = append(out, deadLetterMessage(reason, msg))
+	}
+
+	if err := d.writer.WriteMessages(ctx, out...); err != nil {
+		return err
+	}
+
+	slog.Warn("Dead-lettered events", "count", len(msgs), "reason", reason, "topic", d.writer.Topic)
+	return nil
+}
+
+// deadLetterMessage is msg as published to the DLQ. DLQ headers from an earlier rejection
+// are replaced, so a message that fails again only carries its latest reason.
+func deadLetterMessage(reason string, msg kafka.Message) kafka.Message {
+	headers := withoutDLQHeaders(msg.Headers)
+	headers = append(headers,
+		kafka.Header{Key: HeaderDLQReason, Value: []byte(reason)},
+		kafka.Header{Key: HeaderDLQTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderDLQPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderDLQOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+
+	return kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+		Time:    msg.Time,
+	}
+}
+
+// Close flushes and closes the underlying writer.
+func (d *DeadLetterWriter) Close() error {
+	if d == nil {
+		return nil
+	}
+	return d.writer.Close()
+}
+
+// ReplayDeadLetters reads the DLQ topic and republishes every message to the main
+// analytics topic, so it flows through the normal enrichment pipeline again. It stops at
+// the end of the DLQ as it was when the replay started: events that fail again are
+// dead-lettered past that point and left for the next replay instead of looping. It
+// returns early if ctx is cancelled.
+func ReplayDeadLetters(ctx context.Context, cfg *config.Config) (int, error) {
+	if cfg.KafkaDLQTopic == "" {
+		return 0, errors.New("KAFKA_DLQ_TOPIC is not set")
+	}
+
+	progress, err := replayBounds(ctx, cfg)
+	if err != nil {
+		return 0, err
+	}
+	if progress.done() {
+		slog.Info("Dead-letter topic is empty", "topic", cfg.KafkaDLQTopic)
+		return 0, nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: cfg.KafkaBrokers,
+		Topic:   cfg.KafkaDLQTopic,
+		GroupID: cfg.KafkaDLQReplayGroupID,
+	})
+	defer reader.Close()
+
+	writer := &kafka.Writer{
+		Addr:                   kafka.TCP(cfg.KafkaBrokers...),
+		Topic:                  cfg.KafkaTopic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+	defer writer.Close()
+
+	slog.Info("Replaying dead-lettered events", "from", cfg.KafkaDLQTopic, "to", cfg.KafkaTopic, "group", cfg.KafkaDLQReplayGroupID)
+
+	replayed := 0
+	for !progress.done() {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			return replayed, err
+		}
+		if !progress.take(msg) {
+			continue
+		}
+
+		slog.Debug("Replaying event",
+			"reason", headerValue(msg.Headers, HeaderDLQReason),
+			"partition", headerValue(msg.Headers, HeaderDLQPartition),
+			"offset", headerValue(msg.Headers, HeaderDLQOffset),
+		)
+
+		if err := writer.WriteMessages(ctx, replayMessage(msg)); err != nil {
+			return replayed, err
+		}
+
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	slog.Info("Dead-letter topic drained", "replayed", replayed)
+	return replayed, nil
+}
+
+// replayMessage is a dead-lettered msg as republished to the main topic.
+func replayMessage(msg kafka.Message) kafka.Message {
+	return kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: withoutDLQHeaders(msg.Headers),
+		Time:    msg.Time,
+	}
+}
+
+// replayBounds looks up, for every DLQ partition, where the replay group will start
+// reading and where the partition ends right now.
+func replayBounds(ctx context.Context, cfg *config.Config) (*replayProgress, error) {
+	client := &kafka.Client{Addr: kafka.TCP(cfg.KafkaBrokers...)}
+	topic := cfg.KafkaDLQTopic
+
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, err
+	}
+	if len(meta.Topics) == 0 || errors.Is(meta.Topics[0].Error, kafka.UnknownTopicOrPartition) {
+		// Nothing has ever been dead-lettered.
+		return newReplayProgress(nil, nil), nil
+	}
+	if err := meta.Topics[0].Error; err != nil {
+		return nil, fmt.Errorf("describe %s: %w", topic, err)
+	}
+
+	var ids []int
+	var requests []kafka.OffsetRequest
+	for _, p := range meta.Topics[0].Partitions {
+		ids = append(ids, p.ID)
+		requests = append(requests, kafka.FirstOffsetOf(p.ID), kafka.LastOffsetOf(p.ID))
+	}
+
+	offsets, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}})
+	if err != nil {
+		return nil, err
+	}
+	committed, err := client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{GroupID: cfg.KafkaDLQReplayGroupID, Topics: map[string][]int{topic: ids}})
+	if err != nil {
+		return nil, err
+	}
+	if err := committed.Error; err != nil {
+		return nil, fmt.Errorf("fetch %s offsets: %w", cfg.KafkaDLQReplayGroupID, err)
+	}
+
+	start := map[int]int64{}
+	end := map[int]int64{}
+	for _, p := range offsets.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("list %s/%d offsets: %w", topic, p.Partition, p.Error)
+		}
+		// LastOffset is the offset the next message will get.
+		start[p.Partition], end[p.Partition] = p.FirstOffset, p.LastOffset
+	}
+	for _, p := range committed.Topics[topic] {
+		// Without a commit (-1) the group starts at the first offset; a commit older than
+		// the retention window is reset to it.
+		start[p.Partition] = max(start[p.Partition], p.CommittedOffset)
+	}
+	return newReplayProgress(start, end), nil
+}
+
+// replayProgress tracks how far a replay has to read: for each partition, the offset
+// the DLQ ended at when the replay started.
+type replayProgress struct {
+	end map[int]int64
+	// pending holds the partitions with messages below end not read yet.
+	pending map[int]bool
+}
+
+// newReplayProgress expects the replay to read each partition from start up to, but not
+// including, end.
+func newReplayProgress(start, end map[int]int64) *replayProgress {
+	p := &replayProgress{end: end, pending: map[int]bool{}}
+	for partition, last := range end {
+		if start[partition] < last {
+			p.pending[partition] = true
+		}
+	}
+	return p
+}
+
+// take reports whether msg was already in the DLQ when the replay started, and so
+// should be replayed.
+func (p *replayProgress) take(msg kafka.Message) bool {
+	end, ok := p.end[msg.Partition]
+	if !ok || msg.Offset >= end {
+		delete(p.pending, msg.Partition)
+		return false
+	}
+	if msg.Offset == end-1 {
+		delete(p.pending, msg.Partition)
+	}
+	return true
+}
+
+// done reports whether every partition has been read up to its end.
+func (p *replayProgress) done() bool {
+	return len(p.pending) == 0
+}
+
+func withoutDLQHeaders(headers []kafka.Header) []kafka.Header {
+	out := make([]kafka.Header, 0, len(headers)+4)
+	for _, h := range headers {
+		if !strings.HasPrefix(h.Key, headerDLQPrefix) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
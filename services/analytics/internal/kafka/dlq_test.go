@@ -0,0 +1,111 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetterMessage(t *testing.T) {
+	sent := time.Date(2025, 2, 14, 10, 0, 0, 0, time.UTC)
+	msg := kafka.Message{
+		Topic:     "analytics-event",
+		Partition: 3,
+		Offset:    1234,
+		Key:       []byte("abc"),
+		Value:     []byte(`{"code":"abc"}`),
+		Time:      sent,
+		Headers: []kafka.Header{
+			{Key: "traceparent", Value: []byte("00-abc-def-01")},
+			// Left over from an earlier rejection; replaced, not repeated.
+			{Key: HeaderDLQReason, Value: []byte("decode failed")},
+		},
+	}
+
+	out := deadLetterMessage("insert failed: type mismatch", msg)
+
+	assert.Equal(t, msg.Key, out.Key, "the key keeps events of one alias on one partition")
+	assert.Equal(t, msg.Value, out.Value)
+	assert.Equal(t, sent, out.Time)
+	assert.Empty(t, out.Topic, "the writer picks the DLQ topic")
+	assert.Equal(t, []kafka.Header{
+		{Key: "traceparent", Value: []byte("00-abc-def-01")},
+		{Key: HeaderDLQReason, Value: []byte("insert failed: type mismatch")},
+		{Key: HeaderDLQTopic, Value: []byte("analytics-event")},
+		{Key: HeaderDLQPartition, Value: []byte("3")},
+		{Key: HeaderDLQOffset, Value: []byte("1234")},
+	}, out.Headers)
+}
+
+func TestReplayMessage(t *testing.T) {
+	sent := time.Date(2025, 2, 14, 10, 0, 0, 0, time.UTC)
+	dead := deadLetterMessage("decode failed", kafka.Message{
+		Topic:   "analytics-event",
+		Key:     []byte("abc"),
+		Value:   []byte(`{"code":"abc"}`),
+		Time:    sent,
+		Headers: []kafka.Header{{Key: "traceparent", Value: []byte("00-abc-def-01")}},
+	})
+	dead.Topic, dead.Partition, dead.Offset = "analytics-event-dlq", 0, 7
+
+	out := replayMessage(dead)
+
+	assert.Equal(t, kafka.Message{
+		Key:     []byte("abc"),
+		Value:   []byte(`{"code":"abc"}`),
+		Time:    sent,
+		Headers: []kafka.Header{{Key: "traceparent", Value: []byte("00-abc-def-01")}},
+	}, out)
+}
+
+func TestReplayProgress(t *testing.T) {
+	msg := func(partition int, offset int64) kafka.Message {
+		return kafka.Message{Partition: partition, Offset: offset}
+	}
+
+	t.Run("nothing to replay", func(t *testing.T) {
+		assert.True(t, newReplayProgress(nil, nil).done())
+		// Partition 0 is empty, partition 1 was replayed up to its end before.
+		assert.True(t, newReplayProgress(map[int]int64{0: 0, 1: 5}, map[int]int64{0: 0, 1: 5}).done())
+	})
+
+	t.Run("stops at the end offsets from the start", func(t *testing.T) {
+		p := newReplayProgress(map[int]int64{0: 3, 1: 0, 2: 4}, map[int]int64{0: 5, 1: 1, 2: 4})
+		assert.False(t, p.done())
+
+		assert.True(t, p.take(msg(0, 3)))
+		assert.True(t, p.take(msg(1, 0)))
+		assert.False(t, p.done(), "partition 0 has one more message")
+		assert.True(t, p.take(msg(0, 4)))
+		assert.True(t, p.done())
+	})
+
+	t.Run("messages dead-lettered again are left alone", func(t *testing.T) {
+		p := newReplayProgress(map[int]int64{0: 0, 1: 0}, map[int]int64{0: 2, 1: 1})
+
+		assert.True(t, p.take(msg(0, 0)))
+		// The replayed event failed again and was dead-lettered to partition 1 before
+		// partition 1's original message was read.
+		assert.True(t, p.take(msg(1, 0)))
+		assert.False(t, p.take(msg(1, 1)))
+		assert.False(t, p.done())
+		assert.True(t, p.take(msg(0, 1)))
+		assert.True(t, p.done())
+		assert.False(t, p.take(msg(0, 2)))
+	})
+
+	t.Run("offsets past the end finish a partition with gaps", func(t *testing.T) {
+		p := newReplayProgress(map[int]int64{0: 0}, map[int]int64{0: 3})
+		assert.True(t, p.take(msg(0, 0)))
+		assert.False(t, p.take(msg(0, 3)))
+		assert.True(t, p.done())
+	})
+
+	t.Run("partitions created during the replay are ignored", func(t *testing.T) {
+		p := newReplayProgress(map[int]int64{0: 0}, map[int]int64{0: 1})
+		assert.False(t, p.take(msg(1, 0)))
+		assert.False(t, p.done())
+	})
+}
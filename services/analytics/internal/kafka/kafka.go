@@ -3,8 +3,12 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
@@ -15,8 +19,33 @@ import (
 	"github.com/wintkhantlin/url2short-analytics/internal/geoip"
 	"github.com/wintkhantlin/url2short-analytics/internal/models"
 	"github.com/wintkhantlin/url2short-analytics/internal/parser"
+	"github.com/wintkhantlin/url2short-analytics/internal/visitor"
 )
 
+// Batch processing configuration
+const (
+	batchSize    = 5000
+	batchTimeout = 2 * time.Second
+)
+
+// job is one fetched message travelling through the pipeline. The writer receives jobs
+// in fetch order and waits on done, so offsets are committed in order per partition even
+// though enrichment finishes out of order.
+type job struct {
+	msg  kafka.Message
+	done chan result
+}
+
+type result struct {
+	event  models.AnalyticsEvent
+	reject string
+}
+
+// StartConsumer runs the analytics pipeline: a reader stage fetching from Kafka, a pool
+// of cfg.EnrichWorkers enriching events, and a writer stage batching them into
+// ClickHouse. Stages are connected by bounded channels so a slow stage applies
+// backpressure to the reader. It returns after ctx is cancelled and in-flight events
+// have been flushed.
 func StartConsumer(ctx context.Context, conn clickhouse.Conn, validate *validator.Validate, cfg *config.Config) {
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers: cfg.KafkaBrokers,
@@ -26,102 +55,445 @@ func StartConsumer(ctx context.Context, conn clickhouse.Conn, validate *validato
 
 	defer reader.Close()
 
-	slog.Info("Starting to read analytics events", "topic", cfg.KafkaTopic, "group", cfg.KafkaGroupID)
+	dlq := NewDeadLetterWriter(cfg)
+	defer dlq.Close()
 
-	// Batch processing configuration
-	const batchSize = 5000
-	const batchTimeout = 2 * time.Second
+	slog.Info("Starting to read analytics events", "topic", cfg.KafkaTopic, "group", cfg.KafkaGroupID, "dlq", cfg.KafkaDLQTopic, "workers", cfg.EnrichWorkers)
 
-	batch := make([]models.AnalyticsEvent, 0, batchSize)
-	ticker := time.NewTicker(batchTimeout)
-	defer ticker.Stop()
+	jobs := make(chan job, cfg.EnrichQueueSize)
+	ordered := make(chan job, cfg.EnrichQueueSize)
+
+	var workers sync.WaitGroup
+	for range max(cfg.EnrichWorkers, 1) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			enrichJobs(jobs, validate, max(cfg.EnrichBatchSize, 1))
+		}()
+	}
+
+	go readMessages(ctx, reader, jobs, ordered)
+
+	writeBatches(ctx, reader, conn, dlq, cfg, ordered)
+	workers.Wait()
+}
+
+// readMessages fetches messages until ctx is cancelled, handing each one to the worker
+// pool and, in the same order, to the writer.
+func readMessages(ctx context.Context, reader *kafka.Reader, jobs, ordered chan<- job) {
+	defer close(jobs)
+	defer close(ordered)
 
 	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("Error reading message", "error", err)
+			continue
+		}
+
+		j := job{msg: msg, done: make(chan result, 1)}
 		select {
+		case ordered <- j:
 		case <-ctx.Done():
-			slog.Info("Shutting down Kafka consumer...")
-			if len(batch) > 0 {
-				if err := db.InsertBatch(context.Background(), conn, batch); err != nil {
-					slog.Error("Error inserting final batch into ClickHouse", "error", err)
-				}
-			}
 			return
+		}
+		// Never abandon a job the writer is already waiting on.
+		jobs <- j
+	}
+}
+
+// writeBatches collects enriched events in fetch order, inserts them in batches and
+// commits offsets once every message up to that point is in ClickHouse or the DLQ.
+func writeBatches(ctx context.Context, reader *kafka.Reader, conn clickhouse.Conn, dlq *DeadLetterWriter, cfg *config.Config, ordered <-chan job) {
+	var queue batchQueue
+
+	ticker := time.NewTicker(batchTimeout)
+	defer ticker.Stop()
+
+	// flush writes every batch collected so far. On failure the batch being written stays
+	// frozen and is retried as it is on the next flush.
+	flush := func(ctx context.Context) error {
+		for b := queue.next(); b != nil; b = queue.next() {
+			if err := writeBatch(ctx, reader, conn, dlq, cfg, b); err != nil {
+				return err
+			}
+			queue.done()
+		}
+		return nil
+	}
+
+	for {
+		select {
 		case <-ticker.C:
-			if len(batch) > 0 {
-				if err := db.InsertBatch(ctx, conn, batch); err != nil {
-					slog.Error("Error inserting batch into ClickHouse", "error", err)
-				} else {
-					slog.Info("Successfully inserted batch", "size", len(batch))
-				}
-				batch = batch[:0]
+			if err := flush(ctx); err != nil {
+				slog.Error("Error flushing batch", "error", err, "pending", queue.pending())
 			}
-		default:
-			// Non-blocking read with short timeout to allow ticker to fire
-			readCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
-			msg, err := reader.ReadMessage(readCtx)
-			cancel()
-
-			if err != nil {
-				if err != context.DeadlineExceeded && !errors.Is(err, context.DeadlineExceeded) {
-					slog.Error("Error reading message", "error", err)
+		case j, ok := <-ordered:
+			if !ok {
+				slog.Info("Shutting down Kafka consumer...")
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+				if err := flush(shutdownCtx); err != nil {
+					slog.Error("Error flushing final batch, uncommitted events will be redelivered", "error", err, "pending", queue.pending())
 				}
-				continue
+				cancel()
+				return
 			}
 
-			var event models.AnalyticsEvent
-			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				slog.Error("Error unmarshaling event", "error", err)
-				continue
-			}
+			queue.add(j.msg, <-j.done)
 
-			// 1. Parse User-Agent if present
-			if event.UserAgent != "" {
-				info := parser.ParseUserAgent(event.UserAgent)
-				event.Browser = info.Browser
-				event.OS = info.OS
-				event.Device = info.Device
+			// Stop taking new events while a full batch can't be flushed; the bounded
+			// channels then hold the reader back.
+			for queue.full() && ctx.Err() == nil {
+				err := flush(ctx)
+				if err == nil {
+					break
+				}
+				slog.Error("Error flushing batch", "error", err, "pending", queue.pending())
+				sleepCtx(ctx, cfg.InsertRetryBackoff)
 			}
+		}
+	}
+}
 
-			// 2. Parse IP if present (using shared GeoIP)
-			if event.IP != "" {
-				event.Country, event.State = geoip.GetLocation(event.IP)
-			}
+// writeBatch inserts b's events, dead-letters its rejects and commits its offsets. Each
+// step that succeeds is cleared from b, so calling it again after an error resumes where
+// it stopped.
+func writeBatch(ctx context.Context, reader *kafka.Reader, conn clickhouse.Conn, dlq *DeadLetterWriter, cfg *config.Config, b *pendingBatch) error {
+	if len(b.events) > 0 {
+		insert := func(ctx context.Context, events []models.AnalyticsEvent) error {
+			return db.InsertBatch(ctx, conn, events)
+		}
+		var deadLetter publishFunc
+		if dlq != nil {
+			deadLetter = dlq.Publish
+		}
+		if err := insertWithRetry(ctx, insert, deadLetter, cfg, b.events, b.eventMsgs); err != nil {
+			return err
+		}
+		b.events, b.eventMsgs = nil, nil
+	}
 
-			// Fill defaults
-			if event.Browser == "" {
-				event.Browser = "unknown"
-			}
-			if event.OS == "" {
-				event.OS = "unknown"
-			}
-			if event.Device == "" {
-				event.Device = "unknown"
-			}
-			if event.Country == "" {
-				event.Country = "unknown"
-			}
-			if event.State == "" {
-				event.State = "unknown"
-			}
+	for len(b.rejects) > 0 {
+		if err := dlq.Publish(ctx, b.rejects[0].reason, b.rejects[0].msg); err != nil {
+			return fmt.Errorf("dead-letter rejected event: %w", err)
+		}
+		b.rejects = b.rejects[1:]
+	}
+
+	if err := reader.CommitMessages(ctx, b.msgs...); err != nil {
+		return fmt.Errorf("commit offsets: %w", err)
+	}
+	b.msgs = nil
+	return nil
+}
+
+// pendingBatch is a run of consecutive messages whose offsets are committed together.
+type pendingBatch struct {
+	events []models.AnalyticsEvent
+	// eventMsgs are the source messages of events, kept for the dedup token and so a
+	// rejected insert can be dead-lettered.
+	eventMsgs []kafka.Message
+	rejects   []rejectedMessage
+	// msgs is every message in the batch, including rejected ones. Offsets are only
+	// committed once all of them are either in ClickHouse or in the DLQ.
+	msgs []kafka.Message
+}
+
+// batchQueue holds the batch being collected and, once a write of it has started, the
+// frozen batch being written. A frozen batch never changes, so every retry of its insert
+// carries the same messages and the same dedup token; events arriving meanwhile go into
+// the next batch.
+type batchQueue struct {
+	open   pendingBatch
+	frozen *pendingBatch
+}
+
+func (q *batchQueue) add(msg kafka.Message, r result) {
+	q.open.msgs = append(q.open.msgs, msg)
+	if r.reject != "" {
+		q.open.rejects = append(q.open.rejects, rejectedMessage{msg: msg, reason: r.reject})
+		return
+	}
+	q.open.events = append(q.open.events, r.event)
+	q.open.eventMsgs = append(q.open.eventMsgs, msg)
+}
+
+// next returns the batch to write: the frozen one if its write hasn't finished, otherwise
+// the open one, which is frozen in turn. It returns nil when there is nothing to write.
+func (q *batchQueue) next() *pendingBatch {
+	if q.frozen == nil && len(q.open.msgs) > 0 {
+		b := q.open
+		q.frozen, q.open = &b, pendingBatch{}
+	}
+	return q.frozen
+}
 
-			// Normalize before validation so whitespace and raw device strings don't slip through.
-			event.Transform()
+// done records that the frozen batch has been written.
+func (q *batchQueue) done() {
+	q.frozen = nil
+}
+
+// full reports whether the open batch has reached batchSize.
+func (q *batchQueue) full() bool {
+	return len(q.open.msgs) >= batchSize
+}
+
+// pending is the number of messages not committed yet.
+func (q *batchQueue) pending() int {
+	n := len(q.open.msgs)
+	if q.frozen != nil {
+		n += len(q.frozen.msgs)
+	}
+	return n
+}
 
-			if err := validate.Struct(event); err != nil {
-				slog.Error("Validation failed for event", "error", err)
-				continue
+// enrichJobs takes whatever jobs are already queued, up to batchSize, and enriches them
+// together so each group costs one BatchParse and one BatchLookup round trip. Under light
+// load groups are simply smaller; nothing waits for a group to fill up.
+func enrichJobs(jobs <-chan job, validate *validator.Validate, batchSize int) {
+	group := make([]job, 0, batchSize)
+	msgs := make([]kafka.Message, 0, batchSize)
+
+	for j := range jobs {
+		group = append(group[:0], j)
+	collect:
+		for len(group) < batchSize {
+			select {
+			case next, ok := <-jobs:
+				if !ok {
+					break collect
+				}
+				group = append(group, next)
+			default:
+				break collect
 			}
+		}
+
+		msgs = msgs[:0]
+		for _, j := range group {
+			msgs = append(msgs, j.msg)
+		}
+		for i, r := range enrich(msgs, validate) {
+			group[i].done <- r
+		}
+	}
+}
+
+// enrich decodes messages and fills in user-agent and geo dimensions. Results are in the
+// same order as msgs; a non-empty reject reason means the message should be dead-lettered.
+func enrich(msgs []kafka.Message, validate *validator.Validate) []result {
+	results := make([]result, len(msgs))
+	events := make([]models.AnalyticsEvent, len(msgs))
+
+	var userAgents, ips []string
+	var hints []parser.ClientHints
+	var uaIndex, ipIndex []int
+	for i, msg := range msgs {
+		event := &events[i]
+		if err := json.Unmarshal(msg.Value, event); err != nil {
+			slog.Error("Error unmarshaling event", "error", err)
+			results[i].reject = "decode failed: " + err.Error()
+			continue
+		}
+
+		// Prefer the producer's click time; fall back to the Kafka record timestamp
+		// so replayed or late messages still land in the right bucket.
+		if event.Timestamp.IsZero() {
+			event.Timestamp = msg.Time
+		}
+		if event.Timestamp.IsZero() {
+			event.Timestamp = time.Now()
+		}
+
+		if event.UserAgent != "" {
+			userAgents = append(userAgents, event.UserAgent)
+			hints = append(hints, parser.ClientHints{
+				UA:              event.SecCHUA,
+				Platform:        event.SecCHUAPlatform,
+				PlatformVersion: event.SecCHUAPlatformVersion,
+				Mobile:          event.SecCHUAMobile,
+				Model:           event.SecCHUAModel,
+			})
+			uaIndex = append(uaIndex, i)
+		}
+		if event.IP != "" {
+			ips = append(ips, event.IP)
+			ipIndex = append(ipIndex, i)
+		}
+	}
+
+	// 1. Parse User-Agents if present
+	for n, info := range parser.ParseUserAgentsWithHints(userAgents, hints) {
+		event := &events[uaIndex[n]]
+		event.Browser = info.Browser
+		event.OS = info.OS
+		event.Device = info.Device
+		event.BrowserVersion = info.BrowserVersion
+		event.OSVersion = info.OSVersion
+		event.DeviceBrand = info.DeviceBrand
+		event.DeviceModel = info.DeviceModel
+		event.IsBot = info.IsBot
+		event.BotCategory = info.BotCategory
+		event.BotName = info.BotName
+	}
+
+	// 2. Parse IPs if present (using shared GeoIP)
+	for n, location := range geoip.GetLocations(ips) {
+		event := &events[ipIndex[n]]
+		event.Country = location.Country
+		event.State = location.State
+		event.CountryCode = location.CountryISOCode
+		event.StateCode = location.SubdivisionISOCode
+		event.City = location.City
+		event.Continent = location.Continent
+		event.Latitude = location.Latitude
+		event.Longitude = location.Longitude
+		event.AccuracyRadius = location.AccuracyRadius
+		event.TimeZone = location.TimeZone
+		event.PostalCode = location.PostalCode
+		event.ASN = location.ASN
+		event.ASOrg = location.Organization
+	}
+
+	for i := range events {
+		if results[i].reject != "" {
+			continue
+		}
+		event := events[i]
+
+		// Fill defaults
+		if event.Browser == "" {
+			event.Browser = "unknown"
+		}
+		if event.OS == "" {
+			event.OS = "unknown"
+		}
+		if event.Device == "" {
+			event.Device = "unknown"
+		}
+		if event.Country == "" {
+			event.Country = "unknown"
+		}
+		if event.State == "" {
+			event.State = "unknown"
+		}
+
+		// Normalize before validation so whitespace and raw device strings don't slip through.
+		event.Transform()
+		event.VisitorID = visitor.ID(event.Timestamp, event.IP, event.UserAgent)
+
+		if err := validate.Struct(event); err != nil {
+			slog.Error("Validation failed for event", "error", err)
+			results[i].reject = "validation failed: " + err.Error()
+			continue
+		}
+
+		results[i].event = event
+	}
+
+	return results
+}
+
+// shutdownFlushTimeout bounds the final flush after the consumer context is cancelled.
+const shutdownFlushTimeout = 30 * time.Second
+
+// maxInsertRetryBackoff caps the exponential backoff between insert attempts.
+const maxInsertRetryBackoff = 30 * time.Second
+
+type rejectedMessage struct {
+	msg    kafka.Message
+	reason string
+}
+
+// insertFunc writes a batch of events; db.InsertBatch outside tests.
+type insertFunc func(ctx context.Context, events []models.AnalyticsEvent) error
 
-			batch = append(batch, event)
+// publishFunc dead-letters messages; (*DeadLetterWriter).Publish outside tests.
+type publishFunc func(ctx context.Context, reason string, msgs ...kafka.Message) error
 
-			if len(batch) >= batchSize {
-				if err := db.InsertBatch(ctx, conn, batch); err != nil {
-					slog.Error("Error inserting batch into ClickHouse", "error", err)
-				} else {
-					slog.Info("Successfully inserted batch", "size", len(batch))
+// insertWithRetry writes events to ClickHouse, backing off exponentially between
+// attempts up to maxInsertRetryBackoff. It keeps retrying until the insert succeeds or
+// ctx is cancelled, so while ClickHouse is unreachable the writer takes no new events and
+// the bounded channels hold the reader back.
+//
+// A batch rejected for its data can never succeed, so it is split in half and each half
+// inserted the same way until the rejected events are alone; only those are
+// dead-lettered. Each half is deduplicated by its own offset ranges, so a retried split
+// gives the same tokens. Without a DLQ a rejected batch is retried whole so no events are
+// dropped.
+func insertWithRetry(ctx context.Context, insert insertFunc, deadLetter publishFunc, cfg *config.Config, events []models.AnalyticsEvent, msgs []kafka.Message) error {
+	insertCtx := ctx
+	if cfg.InsertDedup {
+		insertCtx = db.WithDedupToken(ctx, dedupToken(msgs))
+	}
+
+	backoff := cfg.InsertRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := insert(insertCtx, events)
+		if err == nil {
+			slog.Info("Successfully inserted batch", "size", len(events), "attempt", attempt)
+			return nil
+		}
+		dataErr := db.IsDataError(err)
+		slog.Error("Error inserting batch into ClickHouse", "error", err, "size", len(events), "attempt", attempt, "data_error", dataErr)
+
+		if deadLetter != nil && dataErr {
+			if len(events) > 1 {
+				mid := len(events) / 2
+				if err := insertWithRetry(ctx, insert, deadLetter, cfg, events[:mid], msgs[:mid]); err != nil {
+					return err
 				}
-				batch = batch[:0]
+				return insertWithRetry(ctx, insert, deadLetter, cfg, events[mid:], msgs[mid:])
 			}
+			dlqErr := deadLetter(ctx, "insert failed: "+err.Error(), msgs...)
+			if dlqErr == nil {
+				return nil
+			}
+			slog.Error("Error dead-lettering failed event", "error", dlqErr, "size", len(msgs))
+		}
+
+		if !sleepCtx(ctx, backoff) {
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, maxInsertRetryBackoff)
+	}
+}
+
+// dedupToken identifies a batch by the offset range it covers in each partition, so a
+// retried insert of the same messages is dropped by ClickHouse as a duplicate.
+func dedupToken(msgs []kafka.Message) string {
+	type offsetRange struct{ first, last int64 }
+	ranges := map[string]*offsetRange{}
+	for _, msg := range msgs {
+		key := msg.Topic + "/" + strconv.Itoa(msg.Partition)
+		r, ok := ranges[key]
+		if !ok {
+			ranges[key] = &offsetRange{first: msg.Offset, last: msg.Offset}
+			continue
 		}
+		r.first = min(r.first, msg.Offset)
+		r.last = max(r.last, msg.Offset)
+	}
+
+	parts := make([]string, 0, len(ranges))
+	for key, r := range ranges {
+		parts = append(parts, fmt.Sprintf("%s:%d-%d", key, r.first, r.last))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// sleepCtx waits for d and reports false if ctx was cancelled first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
 }
@@ -0,0 +1,157 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wintkhantlin/url2short-analytics/internal/config"
+	"github.com/wintkhantlin/url2short-analytics/internal/models"
+)
+
+func TestDedupToken(t *testing.T) {
+	msgs := []kafka.Message{
+		{Topic: "analytics-event", Partition: 1, Offset: 42},
+		{Topic: "analytics-event", Partition: 0, Offset: 7},
+		{Topic: "analytics-event", Partition: 1, Offset: 40},
+		{Topic: "analytics-event", Partition: 0, Offset: 9},
+		{Topic: "analytics-event", Partition: 1, Offset: 41},
+	}
+
+	assert.Equal(t, "analytics-event/0:7-9,analytics-event/1:40-42", dedupToken(msgs))
+
+	// Order of arrival must not change the token, otherwise retries would not dedupe.
+	reversed := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		reversed[len(msgs)-1-i] = msg
+	}
+	assert.Equal(t, dedupToken(msgs), dedupToken(reversed))
+}
+
+func TestBatchQueue_FreezesBatchUntilWritten(t *testing.T) {
+	var q batchQueue
+	msg := func(offset int64) kafka.Message {
+		return kafka.Message{Topic: "analytics-event", Partition: 0, Offset: offset}
+	}
+	q.add(msg(1), result{event: models.AnalyticsEvent{Code: "a"}})
+	q.add(msg(2), result{reject: "decode failed"})
+	q.add(msg(3), result{event: models.AnalyticsEvent{Code: "b"}})
+
+	first := q.next()
+	require.NotNil(t, first)
+	token := dedupToken(first.eventMsgs)
+	assert.Len(t, first.events, 2)
+	assert.Len(t, first.rejects, 1)
+	assert.Len(t, first.msgs, 3)
+
+	// The insert failed; events arriving before the retry must not join the frozen batch.
+	q.add(msg(4), result{event: models.AnalyticsEvent{Code: "c"}})
+	retry := q.next()
+	assert.Same(t, first, retry)
+	assert.Len(t, retry.events, 2)
+	assert.Equal(t, token, dedupToken(retry.eventMsgs))
+	assert.Equal(t, 4, q.pending())
+
+	q.done()
+	second := q.next()
+	require.NotNil(t, second)
+	assert.Equal(t, "analytics-event/0:4-4", dedupToken(second.eventMsgs))
+
+	q.done()
+	assert.Nil(t, q.next())
+	assert.Zero(t, q.pending())
+}
+
+func TestInsertWithRetry_DeadLettersOnlyBadRows(t *testing.T) {
+	codes := []string{"a", "b", "c", "bad", "e", "f", "g"}
+	events := make([]models.AnalyticsEvent, len(codes))
+	msgs := make([]kafka.Message, len(codes))
+	for i, code := range codes {
+		events[i] = models.AnalyticsEvent{Code: code}
+		msgs[i] = kafka.Message{Topic: "analytics-event", Offset: int64(i), Value: []byte(code)}
+	}
+
+	var inserted []string
+	calls := 0
+	insert := func(ctx context.Context, batch []models.AnalyticsEvent) error {
+		calls++
+		if calls == 1 {
+			return errors.New("connection refused")
+		}
+		for _, event := range batch {
+			if event.Code == "bad" {
+				return &clickhouse.Exception{Code: 53, Message: "type mismatch"}
+			}
+		}
+		for _, event := range batch {
+			inserted = append(inserted, event.Code)
+		}
+		return nil
+	}
+	var deadLettered []kafka.Message
+	deadLetter := func(ctx context.Context, reason string, msgs ...kafka.Message) error {
+		assert.Contains(t, reason, "type mismatch")
+		deadLettered = append(deadLettered, msgs...)
+		return nil
+	}
+
+	cfg := &config.Config{InsertRetryBackoff: time.Millisecond, InsertDedup: true}
+	require.NoError(t, insertWithRetry(context.Background(), insert, deadLetter, cfg, events, msgs))
+
+	assert.Equal(t, []kafka.Message{msgs[3]}, deadLettered)
+	assert.Equal(t, slices.Delete(slices.Clone(codes), 3, 4), inserted, "every good row is inserted once")
+}
+
+func TestEnrich(t *testing.T) {
+	validate := validator.New()
+	sent := time.Date(2025, 2, 14, 10, 0, 0, 0, time.UTC)
+
+	t.Run("undecodable message is rejected", func(t *testing.T) {
+		r := enrichOne(kafka.Message{Value: []byte("{not json")}, validate)
+		assert.Contains(t, r.reject, "decode failed")
+	})
+
+	t.Run("missing code is rejected", func(t *testing.T) {
+		r := enrichOne(kafka.Message{Value: []byte(`{"ip":"8.8.8.8"}`), Time: sent}, validate)
+		assert.Contains(t, r.reject, "validation failed")
+	})
+
+	t.Run("falls back to the Kafka timestamp", func(t *testing.T) {
+		r := enrichOne(kafka.Message{Value: []byte(`{"code":"abc"}`), Time: sent}, validate)
+		assert.Empty(t, r.reject)
+		assert.Equal(t, "abc", r.event.Code)
+		assert.True(t, r.event.Timestamp.Equal(sent))
+	})
+
+	t.Run("keeps the producer timestamp", func(t *testing.T) {
+		r := enrichOne(kafka.Message{Value: []byte(`{"code":"abc","timestamp":"2025-02-13T08:30:00Z"}`), Time: sent}, validate)
+		assert.Empty(t, r.reject)
+		assert.True(t, r.event.Timestamp.Equal(time.Date(2025, 2, 13, 8, 30, 0, 0, time.UTC)))
+	})
+}
+
+func TestEnrich_KeepsOrderWithinBatch(t *testing.T) {
+	results := enrich([]kafka.Message{
+		{Value: []byte(`{"code":"first"}`), Time: time.Now()},
+		{Value: []byte("garbage")},
+		{Value: []byte(`{"code":"third","ip":"203.0.113.7"}`), Time: time.Now()},
+	}, validator.New())
+
+	require.Len(t, results, 3)
+	assert.Equal(t, "first", results[0].event.Code)
+	assert.Contains(t, results[1].reject, "decode failed")
+	assert.Equal(t, "third", results[2].event.Code)
+	// No IP2Geo service in tests, so the lookup falls back to unknown.
+	assert.Equal(t, "unknown", results[2].event.Country)
+}
+
+func enrichOne(msg kafka.Message, validate *validator.Validate) result {
+	return enrich([]kafka.Message{msg}, validate)[0]
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Comparison periods accepted by the compare query parameter.
+const (
+	ComparePrevious = "previous" // the same length of time immediately before
+	CompareYear     = "year"     // the same dates one year earlier
+	CompareCustom   = "custom"   // an explicit compare_start/compare_end
+)
+
+// ComparisonRange returns the period q is compared against for mode. customStart and
+// customEnd are only used for CompareCustom.
+func ComparisonRange(q AnalyticsQuery, mode string, customStart, customEnd time.Time) (time.Time, time.Time, error) {
+	switch mode {
+	case ComparePrevious:
+		// BETWEEN is inclusive at both ends, so the primary period spans End-Start plus a
+		// second. The previous one spans as much and stops a second short of Start.
+		return q.Start.Add(-q.End.Sub(q.Start) - time.Second), q.Start.Add(-time.Second), nil
+	case CompareYear:
+		return q.Start.AddDate(-1, 0, 0), q.End.AddDate(-1, 0, 0), nil
+	case CompareCustom:
+		if customStart.IsZero() || customEnd.IsZero() {
+			return time.Time{}, time.Time{}, fmt.Errorf("compare=%s requires compare_start and compare_end", CompareCustom)
+		}
+		if customEnd.Before(customStart) {
+			return time.Time{}, time.Time{}, fmt.Errorf("compare_start must not be after compare_end")
+		}
+		return customStart, customEnd, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("compare must be one of %s, %s or %s", ComparePrevious, CompareYear, CompareCustom)
+}
+
+// Change is the difference between a current and a previous value. Percent is nil when
+// the previous value is 0.
+type Change struct {
+	Delta   int64    `json:"delta"`
+	Percent *float64 `json:"percent"`
+}
+
+func change(current, previous uint64) Change {
+	c := Change{Delta: int64(current) - int64(previous)}
+	if previous > 0 {
+		percent := float64(c.Delta) / float64(previous) * 100
+		c.Percent = &percent
+	}
+	return c
+}
+
+// ComparisonTimelineEntry is one bucket of the comparison period, lined up with the
+// primary bucket at Time.
+type ComparisonTimelineEntry struct {
+	Time         time.Time `json:"time"`
+	PreviousTime time.Time `json:"previous_time"`
+	Count        uint64    `json:"count"`
+	Visitors     uint64    `json:"visitors"`
+}
+
+// DimensionDelta compares one breakdown row of the primary period with the same name in
+// the comparison period.
+type DimensionDelta struct {
+	Name             string `json:"name"`
+	Count            uint64 `json:"count"`
+	PreviousCount    uint64 `json:"previous_count"`
+	Change           Change `json:"change"`
+	Visitors         uint64 `json:"visitors"`
+	PreviousVisitors uint64 `json:"previous_visitors"`
+	VisitorsChange   Change `json:"visitors_change"`
+}
+
+// AnalyticsComparison is the comparison period's figures relative to the primary period.
+type AnalyticsComparison struct {
+	Start                time.Time                 `json:"start"`
+	End                  time.Time                 `json:"end"`
+	TotalClicks          uint64                    `json:"total_clicks"`
+	UniqueVisitors       uint64                    `json:"unique_visitors"`
+	TotalClicksChange    Change                    `json:"total_clicks_change"`
+	UniqueVisitorsChange Change                    `json:"unique_visitors_change"`
+	Timeline             []ComparisonTimelineEntry `json:"timeline"`
+	Browsers             []DimensionDelta          `json:"browsers"`
+	OS                   []DimensionDelta          `json:"os"`
+	Devices              []DimensionDelta          `json:"devices"`
+	Countries            []DimensionDelta          `json:"countries"`
+	Referrers            []DimensionDelta          `json:"referrers"`
+}
+
+// BreakdownNames lists the names in each breakdown of resp, keyed like
+// AnalyticsQuery.Breakdowns, so a comparison query can fetch exactly those rows.
+func BreakdownNames(resp *AnalyticsResponse) map[string][]string {
+	names := func(rows []DimensionSummary) []string {
+		out := make([]string, 0, len(rows))
+		for _, row := range rows {
+			out = append(out, row.Name)
+		}
+		return out
+	}
+	return map[string][]string{
+		"browser":  names(resp.Browsers),
+		"os":       names(resp.OS),
+		"device":   names(resp.Devices),
+		"country":  names(resp.Countries),
+		"referrer": names(resp.Referrers),
+	}
+}
+
+// Compare builds the comparison of current, the figures for q, against previous, the
+// figures for prev. Both must use the same interval and zone. Timeline buckets are
+// matched by their offset from the start of each range, so the third day lines up with
+// the third day even when a daylight saving change or a gap shifts one series.
+func Compare(current, previous *AnalyticsResponse, q, prev AnalyticsQuery) *AnalyticsComparison {
+	cmp := &AnalyticsComparison{
+		Start:                prev.Start,
+		End:                  prev.End,
+		TotalClicks:          previous.TotalClicks,
+		UniqueVisitors:       previous.UniqueVisitors,
+		TotalClicksChange:    change(current.TotalClicks, previous.TotalClicks),
+		UniqueVisitorsChange: change(current.UniqueVisitors, previous.UniqueVisitors),
+		Timeline:             make([]ComparisonTimelineEntry, len(current.Timeline)),
+		Browsers:             dimensionDeltas(current.Browsers, previous.Browsers),
+		OS:                   dimensionDeltas(current.OS, previous.OS),
+		Devices:              dimensionDeltas(current.Devices, previous.Devices),
+		Countries:            dimensionDeltas(current.Countries, previous.Countries),
+		Referrers:            dimensionDeltas(current.Referrers, previous.Referrers),
+	}
+
+	previousByOffset := make(map[int]TimelineEntry, len(previous.Timeline))
+	for _, entry := range previous.Timeline {
+		previousByOffset[bucketOffset(prev, entry.Time)] = entry
+	}
+	for i, entry := range current.Timeline {
+		cmp.Timeline[i].Time = entry.Time
+		if p, ok := previousByOffset[bucketOffset(q, entry.Time)]; ok {
+			cmp.Timeline[i].PreviousTime = p.Time
+			cmp.Timeline[i].Count = p.Count
+			cmp.Timeline[i].Visitors = p.Visitors
+		}
+	}
+	return cmp
+}
+
+// bucketOffset counts the q.Interval buckets from the one q.Start falls in to bucket, on
+// the calendar of bucket's zone, which is the zone the timeline was grouped in. Minutes
+// and hours are counted in elapsed time, longer intervals on the calendar, so a 23- or
+// 25-hour day still counts as one.
+func bucketOffset(q AnalyticsQuery, bucket time.Time) int {
+	start := q.Start.In(bucket.Location())
+	switch q.Interval {
+	case "minute":
+		return int(bucket.Sub(start.Truncate(time.Minute)) / time.Minute)
+	case "hour":
+		first := time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), 0, 0, 0, start.Location())
+		return int(bucket.Sub(first) / time.Hour)
+	case "day":
+		return daysBetween(start, bucket)
+	case "week":
+		weekStart := time.Sunday
+		if q.WeekStart == WeekStartMonday {
+			weekStart = time.Monday
+		}
+		// Count from the first day of the week start falls in.
+		intoWeek := (int(start.Weekday()) - int(weekStart) + 7) % 7
+		return (daysBetween(start, bucket) + intoWeek) / 7
+	case "month":
+		return (bucket.Year()-start.Year())*12 + int(bucket.Month()) - int(start.Month())
+	default:
+		return bucket.Year() - start.Year()
+	}
+}
+
+// daysBetween counts the calendar days from a's date to b's, each in its own zone.
+func daysBetween(a, b time.Time) int {
+	ad := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	bd := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(bd.Sub(ad) / (24 * time.Hour))
+}
+
+func dimensionDeltas(current, previous []DimensionSummary) []DimensionDelta {
+	previousRows := make(map[string]DimensionSummary, len(previous))
+	for _, row := range previous {
+		previousRows[row.Name] = row
+	}
+
+	deltas := make([]DimensionDelta, 0, len(current))
+	for _, row := range current {
+		p := previousRows[row.Name]
+		deltas = append(deltas, DimensionDelta{
+			Name:             row.Name,
+			Count:            row.Count,
+			PreviousCount:    p.Count,
+			Change:           change(row.Count, p.Count),
+			Visitors:         row.Visitors,
+			PreviousVisitors: p.Visitors,
+			VisitorsChange:   change(row.Visitors, p.Visitors),
+		})
+	}
+	return deltas
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComparisonRange(t *testing.T) {
+	q := AnalyticsQuery{
+		Start: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC),
+	}
+
+	start, end, err := ComparisonRange(q, ComparePrevious, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("previous: %v", err)
+	}
+	if want := time.Date(2025, 3, 2, 23, 59, 59, 0, time.UTC); !start.Equal(want) {
+		t.Fatalf("previous start=%v want=%v", start, want)
+	}
+	if want := q.Start.Add(-time.Second); !end.Equal(want) {
+		t.Fatalf("previous end=%v want=%v", end, want)
+	}
+	if got, want := end.Sub(start), q.End.Sub(q.Start); got != want {
+		t.Fatalf("previous period spans %v, primary %v", got, want)
+	}
+
+	// A typical "last 24 hours" range ending a second before midnight.
+	day := AnalyticsQuery{
+		Start: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2025, 3, 10, 23, 59, 59, 0, time.UTC),
+	}
+	start, end, err = ComparisonRange(day, ComparePrevious, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("previous day: %v", err)
+	}
+	if !start.Equal(time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, 3, 9, 23, 59, 59, 0, time.UTC)) {
+		t.Fatalf("previous day range=%v..%v", start, end)
+	}
+	if end.Sub(start) != day.End.Sub(day.Start) {
+		t.Fatalf("previous day spans %v, primary %v", end.Sub(start), day.End.Sub(day.Start))
+	}
+
+	start, end, err = ComparisonRange(q, CompareYear, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("year: %v", err)
+	}
+	if !start.Equal(q.Start.AddDate(-1, 0, 0)) || !end.Equal(q.End.AddDate(-1, 0, 0)) {
+		t.Fatalf("year range=%v..%v", start, end)
+	}
+
+	if _, _, err := ComparisonRange(q, CompareCustom, time.Time{}, time.Time{}); err == nil {
+		t.Fatal("custom without a range: expected an error")
+	}
+	if _, _, err := ComparisonRange(q, "yesterday", time.Time{}, time.Time{}); err == nil {
+		t.Fatal("unknown mode: expected an error")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t0 := time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC)
+	p0 := t0.AddDate(0, 0, -7)
+
+	current := &AnalyticsResponse{
+		TotalClicks:    150,
+		UniqueVisitors: 40,
+		Timeline: []TimelineEntry{
+			{Time: t0, Count: 100},
+			{Time: t0.Add(time.Hour), Count: 50},
+		},
+		Countries: []DimensionSummary{{Name: "germany", Count: 90, Visitors: 30}, {Name: "france", Count: 60, Visitors: 10}},
+	}
+	previous := &AnalyticsResponse{
+		TotalClicks:    100,
+		UniqueVisitors: 0,
+		Timeline:       []TimelineEntry{{Time: p0, Count: 70}},
+		Countries:      []DimensionSummary{{Name: "germany", Count: 100, Visitors: 20}},
+	}
+
+	q := AnalyticsQuery{Start: t0, End: t0.Add(2*time.Hour - time.Second), Interval: "hour"}
+	prev := AnalyticsQuery{Start: p0, End: p0.Add(2*time.Hour - time.Second), Interval: "hour"}
+	cmp := Compare(current, previous, q, prev)
+	if !cmp.Start.Equal(prev.Start) || !cmp.End.Equal(prev.End) {
+		t.Fatalf("comparison range %v - %v, want %v - %v", cmp.Start, cmp.End, prev.Start, prev.End)
+	}
+
+	if cmp.TotalClicksChange.Delta != 50 || cmp.TotalClicksChange.Percent == nil || *cmp.TotalClicksChange.Percent != 50 {
+		t.Fatalf("total change=%+v", cmp.TotalClicksChange)
+	}
+	if cmp.UniqueVisitorsChange.Delta != 40 || cmp.UniqueVisitorsChange.Percent != nil {
+		t.Fatalf("visitors change=%+v, want no percentage from zero", cmp.UniqueVisitorsChange)
+	}
+
+	if len(cmp.Timeline) != 2 {
+		t.Fatalf("timeline len=%d want 2", len(cmp.Timeline))
+	}
+	if !cmp.Timeline[0].Time.Equal(t0) || !cmp.Timeline[0].PreviousTime.Equal(p0) || cmp.Timeline[0].Count != 70 {
+		t.Fatalf("timeline[0]=%+v", cmp.Timeline[0])
+	}
+	if cmp.Timeline[1].Count != 0 {
+		t.Fatalf("timeline[1]=%+v, want zero past the end of the comparison series", cmp.Timeline[1])
+	}
+
+	germany, france := cmp.Countries[0], cmp.Countries[1]
+	if germany.PreviousCount != 100 || germany.Change.Delta != -10 || *germany.Change.Percent != -10 {
+		t.Fatalf("germany=%+v", germany)
+	}
+	if germany.Visitors != 30 || germany.PreviousVisitors != 20 || germany.VisitorsChange.Delta != 10 || *germany.VisitorsChange.Percent != 50 {
+		t.Fatalf("germany visitors=%+v", germany)
+	}
+	if france.PreviousCount != 0 || france.Change.Delta != 60 || france.Change.Percent != nil {
+		t.Fatalf("france=%+v", france)
+	}
+	if france.Visitors != 10 || france.PreviousVisitors != 0 || france.VisitorsChange.Delta != 10 || france.VisitorsChange.Percent != nil {
+		t.Fatalf("france visitors=%+v", france)
+	}
+}
+
+func TestCompare_AlignsAcrossDaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := func(year, d int) time.Time { return time.Date(year, 3, d, 0, 0, 0, 0, berlin) }
+
+	// Berlin moves to summer time on 30 March 2025, so that day is 23 hours long. A year
+	// earlier the 29th had no clicks and is missing from its series.
+	q := AnalyticsQuery{Start: day(2025, 29), End: day(2025, 32).Add(-time.Second), Interval: "day", TimeZone: "Europe/Berlin"}
+	prev := AnalyticsQuery{Start: day(2024, 29), End: day(2024, 32).Add(-time.Second), Interval: "day", TimeZone: "Europe/Berlin"}
+	current := &AnalyticsResponse{Timeline: []TimelineEntry{
+		{Time: day(2025, 29), Count: 1},
+		{Time: day(2025, 30), Count: 2},
+		{Time: day(2025, 31), Count: 3},
+	}}
+	previous := &AnalyticsResponse{Timeline: []TimelineEntry{
+		{Time: day(2024, 30), Count: 20, Visitors: 2},
+		{Time: day(2024, 31), Count: 30, Visitors: 3},
+	}}
+
+	cmp := Compare(current, previous, q, prev)
+
+	want := []ComparisonTimelineEntry{
+		{Time: day(2025, 29)},
+		{Time: day(2025, 30), PreviousTime: day(2024, 30), Count: 20, Visitors: 2},
+		{Time: day(2025, 31), PreviousTime: day(2024, 31), Count: 30, Visitors: 3},
+	}
+	if len(cmp.Timeline) != len(want) {
+		t.Fatalf("timeline len=%d want %d", len(cmp.Timeline), len(want))
+	}
+	for i := range want {
+		got := cmp.Timeline[i]
+		if !got.Time.Equal(want[i].Time) || !got.PreviousTime.Equal(want[i].PreviousTime) || got.Count != want[i].Count || got.Visitors != want[i].Visitors {
+			t.Errorf("timeline[%d]=%+v want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestBucketOffset(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	yangon, err := time.LoadLocation("Asia/Yangon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(loc *time.Location, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name   string
+		q      AnalyticsQuery
+		bucket time.Time
+		want   int
+	}{
+		{"first minute", AnalyticsQuery{Interval: "minute", Start: at(time.UTC, 3, 1, 10, 0).Add(30 * time.Second)}, at(time.UTC, 3, 1, 10, 0), 0},
+		{"later minute", AnalyticsQuery{Interval: "minute", Start: at(time.UTC, 3, 1, 10, 0)}, at(time.UTC, 3, 1, 11, 5), 65},
+		{"hour in a half-hour zone", AnalyticsQuery{Interval: "hour", Start: at(yangon, 3, 1, 10, 45)}, at(yangon, 3, 1, 12, 0), 2},
+		{"hours across the spring change", AnalyticsQuery{Interval: "hour", Start: at(berlin, 3, 30, 0, 0)}, at(berlin, 3, 30, 3, 0), 2},
+		{"days across the spring change", AnalyticsQuery{Interval: "day", Start: at(berlin, 3, 29, 12, 0)}, at(berlin, 3, 31, 0, 0), 2},
+		{"days across the autumn change", AnalyticsQuery{Interval: "day", Start: at(berlin, 10, 25, 0, 0)}, at(berlin, 10, 27, 0, 0), 2},
+		{"start in another zone", AnalyticsQuery{Interval: "day", Start: at(time.UTC, 3, 1, 23, 30)}, at(berlin, 3, 2, 0, 0), 0},
+		// Wednesday 5 March 2025.
+		{"sunday weeks", AnalyticsQuery{Interval: "week", WeekStart: WeekStartSunday, Start: at(time.UTC, 3, 5, 9, 0)}, at(time.UTC, 3, 16, 0, 0), 2},
+		{"monday weeks", AnalyticsQuery{Interval: "week", WeekStart: WeekStartMonday, Start: at(time.UTC, 3, 5, 9, 0)}, at(time.UTC, 3, 3, 0, 0), 0},
+		{"monday weeks later", AnalyticsQuery{Interval: "week", WeekStart: WeekStartMonday, Start: at(time.UTC, 3, 5, 9, 0)}, at(time.UTC, 3, 31, 0, 0), 4},
+		{"months", AnalyticsQuery{Interval: "month", Start: at(time.UTC, 11, 20, 0, 0).AddDate(-1, 0, 0)}, at(time.UTC, 2, 1, 0, 0), 3},
+		{"years", AnalyticsQuery{Interval: "year", Start: at(time.UTC, 6, 1, 0, 0).AddDate(-2, 0, 0)}, at(time.UTC, 1, 1, 0, 0), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bucketOffset(tt.q, tt.bucket); got != tt.want {
+				t.Fatalf("bucketOffset = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BotFilter selects how bot traffic is treated by analytics queries.
+type BotFilter string
+
+const (
+	BotsExclude BotFilter = "exclude"
+	BotsInclude BotFilter = "include"
+	BotsOnly    BotFilter = "only"
+)
+
+// DimensionFilter keeps rows whose value is in Include (when it is non-empty) and not in
+// Exclude.
+type DimensionFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func (f DimensionFilter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// AnalyticsFilters narrows every query behind an AnalyticsResponse. Values are compared
+// with what the breakdowns return, so a name from the countries list can be fed straight
+// back in as a country filter.
+type AnalyticsFilters struct {
+	Country  DimensionFilter
+	State    DimensionFilter
+	Browser  DimensionFilter
+	OS       DimensionFilter
+	Device   DimensionFilter
+	Referrer DimensionFilter
+	Bots     BotFilter
+}
+
+// FilterDimensions lists the filterable dimensions by their query parameter name.
+var FilterDimensions = []string{"country", "state", "browser", "os", "device", "referrer"}
+
+// Dimension returns the filter for one of FilterDimensions, or nil for an unknown name.
+func (f *AnalyticsFilters) Dimension(name string) *DimensionFilter {
+	switch name {
+	case "country":
+		return &f.Country
+	case "state":
+		return &f.State
+	case "browser":
+		return &f.Browser
+	case "os":
+		return &f.OS
+	case "device":
+		return &f.Device
+	case "referrer":
+		return &f.Referrer
+	}
+	return nil
+}
+
+// Normalize brings filter values into the form they are stored in (see Transform) and
+// defaults Bots to BotsExclude.
+func (f *AnalyticsFilters) Normalize() error {
+	for _, name := range FilterDimensions {
+		dim := f.Dimension(name)
+		normalize := normalizeFilterValue
+		if name == "referrer" {
+			normalize = normalizeRefererDomain
+		}
+		dim.Include = normalizeFilterValues(dim.Include, normalize)
+		dim.Exclude = normalizeFilterValues(dim.Exclude, normalize)
+	}
+
+	switch f.Bots {
+	case "":
+		f.Bots = BotsExclude
+	case BotsExclude, BotsInclude, BotsOnly:
+	default:
+		return fmt.Errorf("bots must be one of %s, %s or %s", BotsExclude, BotsInclude, BotsOnly)
+	}
+	return nil
+}
+
+func normalizeFilterValues(values []string, normalize func(string) string) []string {
+	var out []string
+	for _, value := range values {
+		if value = normalize(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func normalizeFilterValue(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// normalizeRefererDomain accepts "google.com" as well as a full URL and returns the host,
+// which is what the referrers breakdown reports.
+func normalizeRefererDomain(value string) string {
+	referer := normalizeReferer(value)
+	return strings.TrimSuffix(strings.TrimPrefix(referer, "https://"), "/")
+}
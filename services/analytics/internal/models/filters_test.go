@@ -0,0 +1,37 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyticsFiltersNormalize(t *testing.T) {
+	filters := AnalyticsFilters{
+		Country:  DimensionFilter{Include: []string{" Germany", "", "FRANCE "}},
+		Device:   DimensionFilter{Exclude: []string{"Tablet"}},
+		Referrer: DimensionFilter{Include: []string{"https://News.ycombinator.com/item?id=1", "google.com"}},
+	}
+	if err := filters.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+
+	if want := []string{"germany", "france"}; !reflect.DeepEqual(filters.Country.Include, want) {
+		t.Fatalf("country include=%q want=%q", filters.Country.Include, want)
+	}
+	if want := []string{"tablet"}; !reflect.DeepEqual(filters.Device.Exclude, want) {
+		t.Fatalf("device exclude=%q want=%q", filters.Device.Exclude, want)
+	}
+	if want := []string{"news.ycombinator.com", "google.com"}; !reflect.DeepEqual(filters.Referrer.Include, want) {
+		t.Fatalf("referrer include=%q want=%q", filters.Referrer.Include, want)
+	}
+	if filters.Bots != BotsExclude {
+		t.Fatalf("bots=%q want=%q", filters.Bots, BotsExclude)
+	}
+}
+
+func TestAnalyticsFiltersNormalize_RejectsUnknownBotFilter(t *testing.T) {
+	filters := AnalyticsFilters{Bots: "sometimes"}
+	if err := filters.Normalize(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
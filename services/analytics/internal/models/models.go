@@ -2,20 +2,56 @@ package models
 
 import (
 	"net/url"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 )
 
 type AnalyticsEvent struct {
-	Code      string `json:"code" validate:"required" ch:"code"`
-	IP        string `json:"ip" validate:"omitempty"`
-	UserAgent string `json:"userAgent" validate:"omitempty"`
-	Referer   string `json:"referer" validate:"omitempty" ch:"referer"`
-	Browser   string `json:"browser" validate:"required" ch:"browser"`
-	OS        string `json:"os" validate:"required" ch:"os"`
-	Device    string `json:"device" validate:"required" ch:"device_type"`
-	Country   string `json:"country" validate:"required" ch:"country"`
-	State     string `json:"state" validate:"required" ch:"state"`
+	Code      string    `json:"code" validate:"required" ch:"code"`
+	IP        string    `json:"ip" validate:"omitempty" ch:"ip"`
+	UserAgent string    `json:"userAgent" validate:"omitempty" ch:"user_agent"`
+	Referer   string    `json:"referer" validate:"omitempty" ch:"referer"`
+	Browser   string    `json:"browser" validate:"required" ch:"browser"`
+	OS        string    `json:"os" validate:"required" ch:"os"`
+	Device    string    `json:"device" validate:"required" ch:"device_type"`
+	Country   string    `json:"country" validate:"required" ch:"country"`
+	State     string    `json:"state" validate:"required" ch:"state"`
+	Timestamp time.Time `json:"timestamp" validate:"required" ch:"created_at"`
+
+	// Raw User-Agent Client Hints headers sent by the redirect service. They only feed
+	// user-agent enrichment and are not stored.
+	SecCHUA                string `json:"secChUa,omitempty" validate:"omitempty" ch:"-"`
+	SecCHUAPlatform        string `json:"secChUaPlatform,omitempty" validate:"omitempty" ch:"-"`
+	SecCHUAPlatformVersion string `json:"secChUaPlatformVersion,omitempty" validate:"omitempty" ch:"-"`
+	SecCHUAMobile          string `json:"secChUaMobile,omitempty" validate:"omitempty" ch:"-"`
+	SecCHUAModel           string `json:"secChUaModel,omitempty" validate:"omitempty" ch:"-"`
+
+	BrowserVersion string `json:"browserVersion" validate:"omitempty" ch:"browser_version"`
+	OSVersion      string `json:"osVersion" validate:"omitempty" ch:"os_version"`
+	DeviceBrand    string `json:"deviceBrand" validate:"omitempty" ch:"device_brand"`
+	DeviceModel    string `json:"deviceModel" validate:"omitempty" ch:"device_model"`
+
+	CountryCode    string  `json:"countryCode" validate:"omitempty" ch:"country_code"`
+	StateCode      string  `json:"stateCode" validate:"omitempty" ch:"state_code"`
+	City           string  `json:"city" validate:"omitempty" ch:"city"`
+	Continent      string  `json:"continent" validate:"omitempty" ch:"continent"`
+	Latitude       float64 `json:"latitude" validate:"omitempty" ch:"latitude"`
+	Longitude      float64 `json:"longitude" validate:"omitempty" ch:"longitude"`
+	AccuracyRadius uint16  `json:"accuracyRadius" validate:"omitempty" ch:"accuracy_radius"`
+	TimeZone       string  `json:"timeZone" validate:"omitempty" ch:"time_zone"`
+	PostalCode     string  `json:"postalCode" validate:"omitempty" ch:"postal_code"`
+	ASN            uint32  `json:"asn" validate:"omitempty" ch:"asn"`
+	ASOrg          string  `json:"asOrg" validate:"omitempty" ch:"as_org"`
+
+	IsBot       bool   `json:"isBot" ch:"is_bot"`
+	BotCategory string `json:"botCategory" validate:"omitempty" ch:"bot_category"`
+	BotName     string `json:"botName" validate:"omitempty" ch:"bot_name"`
+
+	// VisitorID is a daily-rotating hash of IP and user agent, 0 when unknown. It is set
+	// at ingest; see the visitor package.
+	VisitorID uint64 `json:"visitorId" ch:"visitor_id"`
 }
 
 func normalizeString(value string) string {
@@ -26,25 +62,105 @@ func normalizeString(value string) string {
 	return strings.ToLower(value)
 }
 
-func normalizeDevice(value string) string {
-	value = strings.ToLower(strings.TrimSpace(value))
-	if value == "" {
-		return "desktop"
+// Device classes stored in device_type.
+const (
+	DeviceMobile   = "mobile"
+	DeviceTablet   = "tablet"
+	DeviceDesktop  = "desktop"
+	DeviceTV       = "tv"
+	DeviceConsole  = "console"
+	DeviceWearable = "wearable"
+	DeviceBot      = "bot"
+	DeviceUnknown  = "unknown"
+)
+
+var deviceClasses = []string{DeviceMobile, DeviceTablet, DeviceDesktop, DeviceTV, DeviceConsole, DeviceWearable, DeviceBot, DeviceUnknown}
+
+// Substrings looked for in the lower-cased device family and user agent, most specific
+// class first: an Android TV stick also says "android", a Galaxy Watch also says "mobile".
+var (
+	wearableTokens = []string{"watch", "wear os"}
+	tvTokens       = []string{"smart-tv", "smarttv", "smart tv", "googletv", "android tv", "appletv", "apple tv", "crkey", "chromecast", "roku", "hbbtv", "web0s", "netcast", "bravia"}
+	consoleTokens  = []string{"playstation", "xbox", "nintendo", "ouya"}
+	tabletTokens   = []string{"ipad", "tablet", "kindle", "silk/", "playbook"}
+	mobileTokens   = []string{"iphone", "ipod", "phone", "mobile", "blackberry", "opera mini"}
+	desktopTokens  = []string{"mac", "computer", "desktop", "windows", "linux", "chromebook", "x11"}
+)
+
+// fireTVModel matches Amazon Fire TV model codes (AFTB, AFTMM, AFTSSS, ...). They are
+// too short to look for as substrings, so they only count as a whole device family or
+// model.
+var fireTVModel = regexp.MustCompile(`(?i)\bAFT[A-Z]{1,3}\b`)
+
+// classifyDevice maps the uap device family and model, OS family and raw user agent to a
+// device class. Non-browser clients (our apps, curl) often only tell us the OS, so that
+// is the last resort before "unknown".
+func classifyDevice(device, model, os, userAgent string, isBot bool) string {
+	fireTV := fireTVModel.MatchString(device) || fireTVModel.MatchString(model)
+	device = strings.ToLower(strings.TrimSpace(device))
+	os = strings.ToLower(strings.TrimSpace(os))
+	userAgent = strings.ToLower(userAgent)
+
+	if isBot || device == "spider" {
+		return DeviceBot
+	}
+	// Already classified, e.g. a replayed event or a row written before this taxonomy.
+	if slices.Contains(deviceClasses, device) {
+		return device
+	}
+
+	containsAny := func(tokens []string) bool {
+		for _, token := range tokens {
+			if strings.Contains(device, token) || strings.Contains(userAgent, token) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	// "glass" (Google Glass) is too common a word to look for in the whole user agent.
+	case containsAny(wearableTokens) || strings.Contains(device, "glass") || os == "watchos":
+		return DeviceWearable
+	case containsAny(tvTokens) || fireTV || os == "tvos":
+		return DeviceTV
+	case containsAny(consoleTokens):
+		return DeviceConsole
+	case containsAny(tabletTokens),
+		// Android browsers only say "Mobile" on phones.
+		strings.Contains(userAgent, "mozilla/") && strings.Contains(userAgent, "android") && !strings.Contains(userAgent, "mobile"):
+		return DeviceTablet
+	case containsAny(mobileTokens), os == "ios", os == "android":
+		return DeviceMobile
+	case containsAny(desktopTokens):
+		return DeviceDesktop
 	}
 
-	switch value {
-	case "phone", "mobile", "iphone", "android", "ipad", "tablet":
-		return "mobile"
-	case "computer", "desktop", "pc", "mac", "windows", "linux", "chromebook":
-		return "desktop"
-	case "other", "unknown":
-		return "desktop"
-	default:
-		if strings.Contains(value, "mobile") || strings.Contains(value, "iphone") || strings.Contains(value, "android") || strings.Contains(value, "ipad") || strings.Contains(value, "tablet") || strings.Contains(value, "phone") {
-			return "mobile"
+	switch os {
+	case "windows", "mac os x", "linux", "ubuntu", "fedora", "debian", "chrome os", "freebsd", "openbsd":
+		return DeviceDesktop
+	}
+	return DeviceUnknown
+}
+
+// RollupDevices folds device classes into the original mobile/desktop split: phones,
+// tablets and wearables count as mobile, everything else as desktop. Both buckets are
+// always present so the UI stays stable.
+func RollupDevices(classes []DimensionSummary) []DimensionSummary {
+	// Visitors are summed too: a visitor ID includes the user agent, so one rarely spans
+	// two device classes.
+	mobile := DimensionSummary{Name: DeviceMobile}
+	desktop := DimensionSummary{Name: DeviceDesktop}
+	for _, item := range classes {
+		bucket := &desktop
+		switch item.Name {
+		case DeviceMobile, DeviceTablet, DeviceWearable:
+			bucket = &mobile
 		}
-		return "desktop"
+		bucket.Count += item.Count
+		bucket.Visitors += item.Visitors
 	}
+	return []DimensionSummary{mobile, desktop}
 }
 
 func normalizeReferer(value string) string {
@@ -87,28 +203,77 @@ func (e *AnalyticsEvent) Transform() {
 
 	e.Browser = normalizeString(e.Browser)
 	e.OS = normalizeString(e.OS)
-	e.Device = normalizeDevice(e.Device)
+	e.Device = classifyDevice(e.Device, e.DeviceModel, e.OS, e.UserAgent, e.IsBot)
 	e.Country = normalizeString(e.Country)
 	e.State = normalizeString(e.State)
 	e.Referer = normalizeReferer(e.Referer)
+	e.BrowserVersion = strings.TrimSpace(e.BrowserVersion)
+	e.OSVersion = strings.TrimSpace(e.OSVersion)
+	e.DeviceBrand = strings.TrimSpace(e.DeviceBrand)
+	e.DeviceModel = strings.TrimSpace(e.DeviceModel)
+
+	e.CountryCode = strings.ToUpper(strings.TrimSpace(e.CountryCode))
+	e.StateCode = strings.ToUpper(strings.TrimSpace(e.StateCode))
+	e.City = normalizeString(e.City)
+	e.Continent = normalizeString(e.Continent)
+	e.TimeZone = strings.TrimSpace(e.TimeZone)
+	e.PostalCode = strings.TrimSpace(e.PostalCode)
+	e.ASOrg = strings.TrimSpace(e.ASOrg)
+	e.BotCategory = strings.ToLower(strings.TrimSpace(e.BotCategory))
+	e.BotName = strings.TrimSpace(e.BotName)
+
+	// ClickHouse DateTime has second precision and no zone; keep the instant in UTC.
+	e.Timestamp = e.Timestamp.UTC().Truncate(time.Second)
 }
 
+// Visitors fields are approximate distinct visitor counts. A visitor is counted once per
+// UTC day, so over a multi-day range someone returning daily counts once per day.
 type TimelineEntry struct {
-	Time  time.Time `json:"time" ch:"time"`
-	Count uint64    `json:"count" ch:"count"`
+	Time     time.Time `json:"time" ch:"time"`
+	Count    uint64    `json:"count" ch:"count"`
+	Visitors uint64    `json:"visitors" ch:"visitors"`
 }
 
 type DimensionSummary struct {
-	Name  string `json:"name" ch:"name"`
-	Count uint64 `json:"count" ch:"count"`
+	Name     string `json:"name" ch:"name"`
+	Count    uint64 `json:"count" ch:"count"`
+	Visitors uint64 `json:"visitors" ch:"visitors"`
 }
 
 type AnalyticsResponse struct {
-	TotalClicks uint64             `json:"total_clicks"`
-	Timeline    []TimelineEntry    `json:"timeline"`
-	Browsers    []DimensionSummary `json:"browsers"`
-	OS          []DimensionSummary `json:"os"`
-	Devices     []DimensionSummary `json:"devices"`
-	Countries   []DimensionSummary `json:"countries"`
-	Referrers   []DimensionSummary `json:"referrers"`
+	TotalClicks    uint64             `json:"total_clicks"`
+	UniqueVisitors uint64             `json:"unique_visitors"`
+	Timeline       []TimelineEntry    `json:"timeline"`
+	Browsers       []DimensionSummary `json:"browsers"`
+	OS             []DimensionSummary `json:"os"`
+	Devices        []DimensionSummary `json:"devices"`
+	Countries      []DimensionSummary `json:"countries"`
+	Referrers      []DimensionSummary `json:"referrers"`
+	// Comparison is set when the request asked for a period-over-period comparison.
+	Comparison *AnalyticsComparison `json:"comparison,omitempty"`
+}
+
+// ExportRow is one click event as returned by the raw export.
+type ExportRow struct {
+	Timestamp time.Time `json:"timestamp" ch:"created_at" parquet:"timestamp,timestamp(millisecond)"`
+	Country   string    `json:"country" ch:"country" parquet:"country"`
+	State     string    `json:"state" ch:"state" parquet:"state"`
+	Browser   string    `json:"browser" ch:"browser" parquet:"browser"`
+	OS        string    `json:"os" ch:"os" parquet:"os"`
+	Device    string    `json:"device" ch:"device_type" parquet:"device"`
+	Referrer  string    `json:"referrer" ch:"referer" parquet:"referrer"`
+}
+
+// AliasSummary is one alias's row in the account-wide leaderboard.
+type AliasSummary struct {
+	Code     string `json:"code" ch:"code"`
+	Count    uint64 `json:"count" ch:"count"`
+	Visitors uint64 `json:"visitors" ch:"visitors"`
+}
+
+// AccountAnalyticsResponse covers several aliases: the AnalyticsResponse fields are their
+// combined figures and Aliases ranks them by clicks.
+type AccountAnalyticsResponse struct {
+	AnalyticsResponse
+	Aliases []AliasSummary `json:"aliases"`
 }
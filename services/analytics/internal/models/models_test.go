@@ -1,32 +1,55 @@
 package models
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
-func TestAnalyticsEventTransform_NormalizesDeviceTwoBuckets(t *testing.T) {
+func TestAnalyticsEventTransform_ClassifiesDevice(t *testing.T) {
 	tests := []struct {
-		name   string
-		device string
-		want   string
+		name      string
+		device    string
+		model     string
+		os        string
+		userAgent string
+		isBot     bool
+		want      string
 	}{
-		{name: "iPhone -> mobile", device: "iPhone", want: "mobile"},
-		{name: "Mobile -> mobile", device: "mobile", want: "mobile"},
-		{name: "iPad -> mobile", device: "iPad", want: "mobile"},
-		{name: "Mac -> desktop", device: "Mac", want: "desktop"},
-		{name: "Windows -> desktop", device: "Windows", want: "desktop"},
-		{name: "Unknown -> desktop", device: "unknown", want: "desktop"},
-		{name: "Other -> desktop", device: "Other", want: "desktop"},
-		{name: "Empty -> desktop", device: "", want: "desktop"},
+		{name: "iPhone -> mobile", device: "iPhone", os: "iOS", want: "mobile"},
+		{name: "Mobile -> mobile", device: "mobile", os: "Windows", want: "mobile"},
+		{name: "iPad -> tablet", device: "iPad", os: "iOS", want: "tablet"},
+		{name: "Android without Mobile -> tablet", device: "Samsung SM-T970", os: "Android", userAgent: "Mozilla/5.0 (Linux; Android 13; SM-T970) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", want: "tablet"},
+		{name: "Android with Mobile -> mobile", device: "K", os: "Android", userAgent: "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36", want: "mobile"},
+		{name: "App on Android -> mobile", device: "Pixel 7", os: "Android", userAgent: "URL2Short/2.3 (Android 14; Pixel 7)", want: "mobile"},
+		{name: "Mac -> desktop", device: "Mac", os: "Mac OS X", want: "desktop"},
+		{name: "Other on Windows -> desktop", device: "Other", os: "Windows", want: "desktop"},
+		{name: "Apple TV -> tv", device: "AppleTV", os: "tvOS", want: "tv"},
+		{name: "Smart TV user agent -> tv", device: "Other", os: "Linux", userAgent: "Mozilla/5.0 (SMART-TV; Linux; Tizen 6.0) AppleWebKit/538.1 (KHTML, like Gecko) Version/6.0 TV Safari/538.1", want: "tv"},
+		{name: "Fire TV model -> tv", device: "Amazon AFTMM", model: "AFTMM", os: "Android", userAgent: "Mozilla/5.0 (Linux; Android 9; AFTMM Build/PS7633) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", want: "tv"},
+		{name: "AFT inside a word is not a Fire TV", device: "iPhone", os: "iOS", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 example.com/crafts", want: "mobile"},
+		{name: "Drafts app -> mobile", device: "iPhone", os: "iOS", userAgent: "Drafts/44.1 CFNetwork/1494.0.7 Darwin/23.4.0", want: "mobile"},
+		{name: "Glassdoor app -> mobile", device: "Pixel 8", os: "Android", userAgent: "Glassdoor/10.2 (Android 14; Pixel 8)", want: "mobile"},
+		{name: "Google Glass -> wearable", device: "Glass 1", os: "Android", userAgent: "Mozilla/5.0 (Linux; U; Android 4.4.2; en-us; Glass 1 Build/KOT49H) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Mobile Safari/537.36", want: "wearable"},
+		{name: "PlayStation -> console", device: "PlayStation 5", os: "Other", want: "console"},
+		{name: "Apple Watch -> wearable", device: "Apple Watch", os: "watchOS", want: "wearable"},
+		{name: "Bot flag -> bot", device: "Other", os: "Other", isBot: true, want: "bot"},
+		{name: "Spider -> bot", device: "Spider", os: "Other", want: "bot"},
+		{name: "Other on unknown OS -> unknown", device: "Other", os: "Other", want: "unknown"},
+		{name: "Empty -> unknown", device: "", os: "", want: "unknown"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			event := AnalyticsEvent{
-				Code:    "abc",
-				Browser: "Chrome",
-				OS:      "Windows",
-				Device:  tt.device,
-				Country: "US",
-				State:   "CA",
+				Code:        "abc",
+				Browser:     "Chrome",
+				OS:          tt.os,
+				Device:      tt.device,
+				DeviceModel: tt.model,
+				UserAgent:   tt.userAgent,
+				IsBot:       tt.isBot,
+				Country:     "US",
+				State:       "CA",
 			}
 
 			event.Transform()
@@ -37,6 +60,20 @@ func TestAnalyticsEventTransform_NormalizesDeviceTwoBuckets(t *testing.T) {
 	}
 }
 
+func TestRollupDevices(t *testing.T) {
+	got := RollupDevices([]DimensionSummary{
+		{Name: "desktop", Count: 5},
+		{Name: "mobile", Count: 4},
+		{Name: "tablet", Count: 3},
+		{Name: "tv", Count: 2},
+		{Name: "wearable", Count: 1},
+	})
+	want := []DimensionSummary{{Name: "mobile", Count: 8}, {Name: "desktop", Count: 7}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
 func TestAnalyticsEventTransform_CleansReferer(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -69,3 +106,18 @@ func TestAnalyticsEventTransform_CleansReferer(t *testing.T) {
 		})
 	}
 }
+
+func TestAnalyticsEventTransform_NormalizesTimestamp(t *testing.T) {
+	yangon := time.FixedZone("Asia/Yangon", 6*3600+30*60)
+	event := AnalyticsEvent{
+		Code:      "abc",
+		Timestamp: time.Date(2025, 2, 14, 6, 45, 10, 999, yangon),
+	}
+
+	event.Transform()
+
+	want := time.Date(2025, 2, 14, 0, 15, 10, 0, time.UTC)
+	if !event.Timestamp.Equal(want) || event.Timestamp.Location() != time.UTC {
+		t.Fatalf("timestamp=%v want=%v", event.Timestamp, want)
+	}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnknownTimeZone is returned for a tz that is not a loadable IANA zone.
+var ErrUnknownTimeZone = errors.New("unknown time zone")
+
+// WeekStart selects the first day of a "week" timeline bucket.
+type WeekStart string
+
+const (
+	WeekStartSunday WeekStart = "sunday"
+	// WeekStartMonday gives ISO 8601 weeks.
+	WeekStartMonday WeekStart = "monday"
+)
+
+// AnalyticsQuery describes one analytics request.
+type AnalyticsQuery struct {
+	Code string
+	// Codes, when non-nil, replaces Code: the figures cover all of these aliases combined.
+	Codes    []string
+	Start    time.Time
+	End      time.Time
+	Interval string
+	// TimeZone is the IANA zone timeline buckets are aligned to. Empty uses the ClickHouse
+	// server's zone.
+	TimeZone  string
+	WeekStart WeekStart
+	Filters   AnalyticsFilters
+	// Breakdowns, when set, limits a breakdown (keyed like FilterDimensions) to the given
+	// names. Comparison queries use it to fetch the rows the primary period returned.
+	Breakdowns map[string][]string
+}
+
+// Normalize validates the query and fills in defaults: hourly buckets (also for an
+// unknown interval), Sunday-start weeks and bots excluded.
+func (q *AnalyticsQuery) Normalize() error {
+	switch q.Interval {
+	case "minute", "hour", "day", "week", "month", "year":
+	default:
+		q.Interval = "hour"
+	}
+
+	switch q.WeekStart {
+	case "":
+		q.WeekStart = WeekStartSunday
+	case WeekStartSunday, WeekStartMonday:
+	default:
+		return fmt.Errorf("week_start must be %s or %s", WeekStartSunday, WeekStartMonday)
+	}
+
+	// "Local" would mean this process's zone, which says nothing about the viewer.
+	if q.TimeZone != "" {
+		if _, err := time.LoadLocation(q.TimeZone); err != nil || q.TimeZone == "Local" {
+			return fmt.Errorf("%w %q", ErrUnknownTimeZone, q.TimeZone)
+		}
+	}
+
+	if q.End.Before(q.Start) {
+		return fmt.Errorf("start must not be after end")
+	}
+
+	return q.Filters.Normalize()
+}
+
+// intervalLengths are the shortest possible length of each interval, so TimelinePoints
+// never underestimates.
+var intervalLengths = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    23 * time.Hour, // DST
+	"week":   7*24*time.Hour - time.Hour,
+	"month":  28 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// TimelinePoints is an upper bound on the number of buckets in the gap-filled timeline.
+func (q AnalyticsQuery) TimelinePoints() int {
+	length, ok := intervalLengths[q.Interval]
+	if !ok {
+		length = time.Hour
+	}
+	// Partial buckets at both ends.
+	return int(q.End.Sub(q.Start)/length) + 2
+}
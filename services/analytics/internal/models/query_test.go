@@ -0,0 +1,49 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyticsQueryNormalize(t *testing.T) {
+	q := AnalyticsQuery{Interval: "fortnight", TimeZone: "Asia/Yangon"}
+	if err := q.Normalize(); err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if q.Interval != "hour" || q.WeekStart != WeekStartSunday || q.Filters.Bots != BotsExclude {
+		t.Fatalf("unexpected defaults: %+v", q)
+	}
+
+	for _, tz := range []string{"Mars/Olympus_Mons", "Local"} {
+		q := AnalyticsQuery{TimeZone: tz}
+		if err := q.Normalize(); err == nil {
+			t.Fatalf("tz=%q: expected an error", tz)
+		}
+	}
+
+	q = AnalyticsQuery{WeekStart: "friday"}
+	if err := q.Normalize(); err == nil {
+		t.Fatal("week_start=friday: expected an error")
+	}
+}
+
+func TestAnalyticsQueryTimelinePoints(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		interval string
+		end      time.Time
+		want     int
+	}{
+		{interval: "hour", end: start.Add(24 * time.Hour), want: 26},
+		{interval: "minute", end: start.Add(24 * time.Hour), want: 1442},
+		{interval: "day", end: start.AddDate(0, 0, 30), want: 33},
+		{interval: "month", end: start.AddDate(1, 0, 0), want: 15},
+	}
+
+	for _, tt := range tests {
+		q := AnalyticsQuery{Start: start, End: tt.end, Interval: tt.interval}
+		if got := q.TimelinePoints(); got != tt.want {
+			t.Fatalf("interval=%s points=%d want=%d", tt.interval, got, tt.want)
+		}
+	}
+}
@@ -3,26 +3,33 @@ package parser
 import (
 	"context"
 	"log/slog"
+	"strings"
 	"sync"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/wintkhantlin/url2short-analytics/internal/cache"
 	pb "github.com/wintkhantlin/url2short-useragent/gen"
 )
 
 var (
-	client pb.UserAgentServiceClient
-	conn   *grpc.ClientConn
-	once   sync.Once
+	client  pb.UserAgentServiceClient
+	conn    *grpc.ClientConn
+	once    sync.Once
+	uaCache *cache.LRU[string, UserAgentInfo]
 )
 
-// Init initializes the UserAgent gRPC client.
-func Init(addr string) error {
+// Init initializes the UserAgent gRPC client. Successful lookups are cached for cacheTTL
+// in an LRU of cacheSize entries; a cacheSize of 0 disables caching.
+func Init(addr string, cacheSize int, cacheTTL time.Duration) error {
 	var err error
 	once.Do(func() {
-		slog.Info("Connecting to UserAgent service", "addr", addr)
+		uaCache = cache.New[string, UserAgentInfo](cacheSize, cacheTTL)
+		cache.Publish("useragent_cache", func() cache.Stats { return uaCache.Stats() })
+
+		slog.Info("Connecting to UserAgent service", "addr", addr, "cache_size", cacheSize, "cache_ttl", cacheTTL)
 
 		// Create a connection to the server
 		conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
@@ -37,9 +44,55 @@ func Init(addr string) error {
 }
 
 type UserAgentInfo struct {
-	Browser string
-	OS      string
-	Device  string
+	Browser        string
+	OS             string
+	Device         string
+	BrowserVersion string
+	OSVersion      string
+	DeviceBrand    string
+	DeviceModel    string
+	IsBot          bool
+	BotCategory    string
+	BotName        string
+}
+
+func fromResponse(resp *pb.UserAgentResponse) UserAgentInfo {
+	return UserAgentInfo{
+		Browser:        resp.Browser,
+		OS:             resp.Os,
+		Device:         resp.Device,
+		BrowserVersion: resp.BrowserVersion,
+		OSVersion:      resp.OsVersion,
+		DeviceBrand:    resp.DeviceBrand,
+		DeviceModel:    resp.DeviceModel,
+		IsBot:          resp.IsBot,
+		BotCategory:    resp.BotCategory,
+		BotName:        resp.BotName,
+	}
+}
+
+// ClientHints carries the raw Sec-CH-UA* header values that accompany a user agent.
+type ClientHints struct {
+	UA              string
+	Platform        string
+	PlatformVersion string
+	Mobile          string
+	Model           string
+}
+
+// cacheKey identifies a user agent together with its client hints; without hints it is
+// just the user agent.
+func cacheKey(userAgent string, hints ClientHints) string {
+	if hints == (ClientHints{}) {
+		return userAgent
+	}
+	return strings.Join([]string{userAgent, hints.UA, hints.Platform, hints.PlatformVersion, hints.Mobile, hints.Model}, "\x00")
+}
+
+var unknownInfo = UserAgentInfo{
+	Browser: "unknown",
+	OS:      "unknown",
+	Device:  "unknown",
 }
 
 // ParseUserAgent parses the user agent string and returns browser, OS, and device information.
@@ -60,6 +113,10 @@ func ParseUserAgent(userAgent string) UserAgentInfo {
 		}
 	}
 
+	if info, ok := uaCache.Get(userAgent); ok {
+		return info
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
@@ -73,11 +130,81 @@ func ParseUserAgent(userAgent string) UserAgentInfo {
 		}
 	}
 
-	return UserAgentInfo{
-		Browser: resp.Browser,
-		OS:      resp.Os,
-		Device:  resp.Device,
+	info := fromResponse(resp)
+	uaCache.Add(userAgent, info)
+	return info
+}
+
+// ParseUserAgents parses many user agents with a single BatchParse call. Results are in
+// the same order as userAgents and follow the same fallbacks as ParseUserAgent.
+func ParseUserAgents(userAgents []string) []UserAgentInfo {
+	return ParseUserAgentsWithHints(userAgents, nil)
+}
+
+// ParseUserAgentsWithHints is ParseUserAgents with the client hints sent alongside each
+// user agent; hints may be nil or shorter than userAgents.
+func ParseUserAgentsWithHints(userAgents []string, hints []ClientHints) []UserAgentInfo {
+	infos := make([]UserAgentInfo, len(userAgents))
+
+	// Answer from the cache where possible and send each remaining user agent only once,
+	// remembering every position it appeared at.
+	var req pb.UserAgentBatchRequest
+	var keys []string
+	positions := map[string][]int{}
+	for i, userAgent := range userAgents {
+		infos[i] = unknownInfo
+		if userAgent == "" {
+			continue
+		}
+		var h ClientHints
+		if i < len(hints) {
+			h = hints[i]
+		}
+		key := cacheKey(userAgent, h)
+		if info, ok := uaCache.Get(key); ok {
+			infos[i] = info
+			continue
+		}
+		if _, queued := positions[key]; !queued {
+			req.Requests = append(req.Requests, &pb.UserAgentRequest{
+				UserAgent:              userAgent,
+				SecChUa:                h.UA,
+				SecChUaPlatform:        h.Platform,
+				SecChUaPlatformVersion: h.PlatformVersion,
+				SecChUaMobile:          h.Mobile,
+				SecChUaModel:           h.Model,
+			})
+			keys = append(keys, key)
+		}
+		positions[key] = append(positions[key], i)
+	}
+
+	if len(req.Requests) == 0 || client == nil {
+		return infos
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.BatchParse(ctx, &req)
+	if err != nil {
+		slog.Debug("Failed to batch parse user agents", "count", len(req.Requests), "error", err)
+		return infos
 	}
+
+	for i, result := range resp.Results {
+		if i >= len(req.Requests) {
+			break
+		}
+		key := keys[i]
+		info := fromResponse(result)
+		uaCache.Add(key, info)
+		for _, pos := range positions[key] {
+			infos[pos] = info
+		}
+	}
+
+	return infos
 }
 
 // Close closes the gRPC connection.
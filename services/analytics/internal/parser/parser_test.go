@@ -2,12 +2,15 @@ package parser
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
 
+	"github.com/wintkhantlin/url2short-analytics/internal/cache"
 	pb "github.com/wintkhantlin/url2short-useragent/gen"
 )
 
@@ -17,17 +20,40 @@ func (fakeUserAgentClient) Parse(ctx context.Context, in *pb.UserAgentRequest, o
 	ua := in.GetUserAgent()
 
 	switch {
+	case in.GetSecChUaModel() != "":
+		model := strings.Trim(in.GetSecChUaModel(), `"`)
+		return &pb.UserAgentResponse{Browser: "Chrome Mobile", Os: "Android", Device: model, DeviceModel: model}, nil
 	case strings.Contains(ua, "Chrome/120.0.0.0") && strings.Contains(ua, "Mac OS X"):
 		return &pb.UserAgentResponse{Browser: "Chrome", Os: "Mac OS X", Device: "Mac"}, nil
 	case strings.Contains(ua, "Firefox/119.0") && strings.Contains(ua, "Windows NT 10.0"):
 		return &pb.UserAgentResponse{Browser: "Firefox", Os: "Windows", Device: "Other"}, nil
 	case strings.Contains(ua, "iPhone") && strings.Contains(ua, "Mobile/") && strings.Contains(ua, "Safari/"):
-		return &pb.UserAgentResponse{Browser: "Mobile Safari", Os: "iOS", Device: "iPhone"}, nil
+		return &pb.UserAgentResponse{
+			Browser: "Mobile Safari", Os: "iOS", Device: "iPhone",
+			BrowserVersion: "17.1", OsVersion: "17.1", DeviceBrand: "Apple", DeviceModel: "iPhone",
+		}, nil
 	default:
 		return &pb.UserAgentResponse{Browser: "unknown", Os: "unknown", Device: "unknown"}, nil
 	}
 }
 
+func (f fakeUserAgentClient) BatchParse(ctx context.Context, in *pb.UserAgentBatchRequest, opts ...grpc.CallOption) (*pb.UserAgentBatchResponse, error) {
+	var resp pb.UserAgentBatchResponse
+	for _, req := range in.GetRequests() {
+		result, _ := f.Parse(ctx, req, opts...)
+		resp.Results = append(resp.Results, result)
+	}
+	return &resp, nil
+}
+
+func (fakeUserAgentClient) StreamParse(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[pb.UserAgentRequest, pb.UserAgentResponse], error) {
+	return nil, errors.New("not implemented")
+}
+
+func (fakeUserAgentClient) Info(ctx context.Context, in *pb.InfoRequest, opts ...grpc.CallOption) (*pb.InfoResponse, error) {
+	return &pb.InfoResponse{Source: "builtin"}, nil
+}
+
 func TestParseUserAgent(t *testing.T) {
 	prev := client
 	client = fakeUserAgentClient{}
@@ -60,9 +86,13 @@ func TestParseUserAgent(t *testing.T) {
 			name:      "iPhone Safari",
 			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
 			expected: UserAgentInfo{
-				Browser: "Mobile Safari",
-				OS:      "iOS",
-				Device:  "iPhone",
+				Browser:        "Mobile Safari",
+				OS:             "iOS",
+				Device:         "iPhone",
+				BrowserVersion: "17.1",
+				OSVersion:      "17.1",
+				DeviceBrand:    "Apple",
+				DeviceModel:    "iPhone",
 			},
 		},
 		{
@@ -83,3 +113,74 @@ func TestParseUserAgent(t *testing.T) {
 		})
 	}
 }
+
+func TestParseUserAgents(t *testing.T) {
+	prev := client
+	client = fakeUserAgentClient{}
+	t.Cleanup(func() { client = prev })
+
+	got := ParseUserAgents([]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/119.0",
+		"",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	})
+
+	assert.Equal(t, []UserAgentInfo{
+		{Browser: "Firefox", OS: "Windows", Device: "Other"},
+		{Browser: "unknown", OS: "unknown", Device: "unknown"},
+		{Browser: "Chrome", OS: "Mac OS X", Device: "Mac"},
+	}, got)
+}
+
+type countingUserAgentClient struct {
+	fakeUserAgentClient
+	sent int
+}
+
+func (c *countingUserAgentClient) BatchParse(ctx context.Context, in *pb.UserAgentBatchRequest, opts ...grpc.CallOption) (*pb.UserAgentBatchResponse, error) {
+	c.sent += len(in.GetRequests())
+	return c.fakeUserAgentClient.BatchParse(ctx, in, opts...)
+}
+
+func TestParseUserAgents_UsesCache(t *testing.T) {
+	prevClient, prevCache := client, uaCache
+	fake := &countingUserAgentClient{}
+	client = fake
+	uaCache = cache.New[string, UserAgentInfo](10, time.Minute)
+	t.Cleanup(func() { client, uaCache = prevClient, prevCache })
+
+	firefox := "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/119.0"
+
+	first := ParseUserAgents([]string{firefox, firefox, firefox})
+	assert.Equal(t, 1, fake.sent, "duplicates within a batch are sent once")
+	assert.Equal(t, "Firefox", first[2].Browser)
+
+	second := ParseUserAgents([]string{firefox})
+	assert.Equal(t, 1, fake.sent, "second batch is served from the cache")
+	assert.Equal(t, first[0], second[0])
+	assert.Equal(t, uint64(1), uaCache.Stats().Hits)
+}
+
+func TestParseUserAgentsWithHints_KeysCacheOnHints(t *testing.T) {
+	prevClient, prevCache := client, uaCache
+	fake := &countingUserAgentClient{}
+	client = fake
+	uaCache = cache.New[string, UserAgentInfo](10, time.Minute)
+	t.Cleanup(func() { client, uaCache = prevClient, prevCache })
+
+	// Frozen Chromium user agent: identical for every Android device.
+	frozen := "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+
+	got := ParseUserAgentsWithHints(
+		[]string{frozen, frozen, frozen},
+		[]ClientHints{{Model: `"Pixel 7"`}, {Model: `"SM-S918B"`}, {Model: `"Pixel 7"`}},
+	)
+	assert.Equal(t, 2, fake.sent, "same user agent with different hints is parsed separately")
+	assert.Equal(t, "Pixel 7", got[0].DeviceModel)
+	assert.Equal(t, "SM-S918B", got[1].DeviceModel)
+	assert.Equal(t, got[0], got[2])
+
+	again := ParseUserAgentsWithHints([]string{frozen}, []ClientHints{{Model: `"SM-S918B"`}})
+	assert.Equal(t, 2, fake.sent, "served from the cache")
+	assert.Equal(t, "SM-S918B", again[0].DeviceModel)
+}
@@ -0,0 +1,61 @@
+package visitor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+var secret atomic.Pointer[[]byte]
+
+// Init sets the secret visitor IDs are keyed with. Every consumer must share it, or the
+// same visitor gets a different ID per instance and after every restart, and is counted
+// more than once. An empty key is an error unless dev is set; a development instance
+// then gets a random key, good for a single process.
+func Init(key string, dev bool) error {
+	b := []byte(key)
+	if len(b) == 0 {
+		if !dev {
+			return errors.New("VISITOR_ID_SECRET is not set")
+		}
+		slog.Warn("VISITOR_ID_SECRET is not set; using a random per-process secret, unique visitor counts will be inflated across restarts")
+		b = make([]byte, 32)
+		rand.Read(b)
+	}
+	secret.Store(&b)
+	return nil
+}
+
+// ID returns an opaque visitor ID for a click at ts from ip with userAgent: a keyed HMAC
+// of both and the UTC day, so the ID cannot be reversed, and the same person gets an
+// unrelated ID the next day. It adds nothing to what is kept about a click, since the raw
+// IP and user agent are stored in their own columns. ID is 0, meaning "unknown", when
+// there is neither an IP nor a user agent.
+func ID(ts time.Time, ip, userAgent string) uint64 {
+	if ip == "" && userAgent == "" {
+		return 0
+	}
+
+	var key []byte
+	if k := secret.Load(); k != nil {
+		key = *k
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(ts.UTC().Format(time.DateOnly)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(ip))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userAgent))
+
+	id := binary.BigEndian.Uint64(mac.Sum(nil))
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
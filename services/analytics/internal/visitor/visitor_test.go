@@ -0,0 +1,41 @@
+package visitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestID(t *testing.T) {
+	assert.NoError(t, Init("test-secret", false))
+
+	ua := "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/119.0"
+	morning := time.Date(2025, 3, 12, 8, 0, 0, 0, time.UTC)
+	evening := time.Date(2025, 3, 12, 22, 0, 0, 0, time.UTC)
+	nextDay := morning.AddDate(0, 0, 1)
+
+	id := ID(morning, "203.0.113.7", ua)
+	assert.NotZero(t, id)
+	assert.Equal(t, id, ID(evening, "203.0.113.7", ua), "stable within a UTC day")
+	assert.NotEqual(t, id, ID(nextDay, "203.0.113.7", ua), "rotates daily")
+	assert.NotEqual(t, id, ID(morning, "203.0.113.8", ua), "depends on the IP")
+	assert.NotEqual(t, id, ID(morning, "203.0.113.7", ua+" extra"), "depends on the user agent")
+	assert.Zero(t, ID(morning, "", ""), "unknown without IP and user agent")
+
+	assert.NoError(t, Init("other-secret", false))
+	assert.NotEqual(t, id, ID(morning, "203.0.113.7", ua), "depends on the secret")
+}
+
+func TestInit_RequiresSecretOutsideDevelopment(t *testing.T) {
+	assert.NoError(t, Init("test-secret", false))
+	ua := "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/119.0"
+	at := time.Date(2025, 3, 12, 8, 0, 0, 0, time.UTC)
+	id := ID(at, "203.0.113.7", ua)
+
+	assert.Error(t, Init("", false))
+	assert.Equal(t, id, ID(at, "203.0.113.7", ua), "a rejected key leaves the current one in place")
+
+	assert.NoError(t, Init("", true))
+	assert.NotEqual(t, id, ID(at, "203.0.113.7", ua), "development gets a random key")
+}
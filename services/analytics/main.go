@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo; needed to validate ?tz=
 
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/go-playground/validator/v10"
@@ -16,24 +17,44 @@ import (
 	"github.com/wintkhantlin/url2short-analytics/internal/geoip"
 	"github.com/wintkhantlin/url2short-analytics/internal/kafka"
 	"github.com/wintkhantlin/url2short-analytics/internal/parser"
+	"github.com/wintkhantlin/url2short-analytics/internal/visitor"
 )
 
 func main() {
 	cfg := config.Load()
 	validate := validator.New()
 
+	// `analytics replay-dlq` pushes dead-lettered events back onto the main topic and exits.
+	if len(os.Args) > 1 && os.Args[1] == "replay-dlq" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		replayed, err := kafka.ReplayDeadLetters(ctx, cfg)
+		if err != nil {
+			slog.Error("Dead-letter replay failed", "replayed", replayed, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Dead-letter replay finished", "replayed", replayed)
+		return
+	}
+
 	// 1. Initialize GeoIP
-	if err := geoip.Init(cfg.IP2GeoAddr); err != nil {
+	if err := geoip.Init(cfg.IP2GeoAddr, cfg.GeoCacheSize, cfg.GeoCacheTTL); err != nil {
 		slog.Warn("GeoIP initialization failed (continuing without it)", "error", err)
 	}
 	defer geoip.Close()
 
 	// 2. Initialize UserAgent Parser
-	if err := parser.Init(cfg.UserAgentAddr); err != nil {
+	if err := parser.Init(cfg.UserAgentAddr, cfg.UserAgentCacheSize, cfg.UserAgentCacheTTL); err != nil {
 		slog.Warn("UserAgent initialization failed (continuing without it)", "error", err)
 	}
 	defer parser.Close()
 
+	if err := visitor.Init(cfg.VisitorIDSecret, cfg.AppEnv == "development"); err != nil {
+		slog.Error("Visitor IDs are not configured", "error", err)
+		os.Exit(1)
+	}
+
 	// 3. Connect to ClickHouse
 	var conn clickhouse.Conn
 	var err error
@@ -56,8 +77,9 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// 3. Expose API (Gin)
+	// 3. Expose API (Gin) and internal metrics
 	go api.Start(conn, cfg)
+	go api.StartMetrics(cfg)
 
 	// 4. Kafka Consumer
 	kafka.StartConsumer(ctx, conn, validate, cfg)
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Port string
+	// CityDBPath is required; ASNDBPath is optional and enables asn/organization.
+	CityDBPath string
+	ASNDBPath  string
+	// Languages is the preference order for place names. English is always tried last.
+	Languages []string
+	// WatchInterval is how often the database files are checked for updates; 0 disables it.
+	WatchInterval time.Duration
+}
+
+// Load reads configuration from the environment; command-line flags override it.
+// Invalid values panic.
+func Load() *Config {
+	return load(flag.CommandLine, os.Args[1:])
+}
+
+func load(fs *flag.FlagSet, args []string) *Config {
+	cfg := &Config{}
+	var languages string
+
+	fs.StringVar(&cfg.Port, "port", getEnv("PORT", "50050"), "gRPC listen port (PORT)")
+	fs.StringVar(&cfg.CityDBPath, "city-db", getEnv("GEOIP_CITY_DB_PATH", "./db/GeoLite2-City.mmdb"), "path to the GeoLite2-City database (GEOIP_CITY_DB_PATH)")
+	fs.StringVar(&cfg.ASNDBPath, "asn-db", getEnv("GEOIP_ASN_DB_PATH", ""), "optional path to the GeoLite2-ASN database (GEOIP_ASN_DB_PATH)")
+	fs.StringVar(&languages, "languages", getEnv("GEOIP_LANGUAGES", "en"), "comma-separated place name languages in order of preference (GEOIP_LANGUAGES)")
+	fs.DurationVar(&cfg.WatchInterval, "watch-interval", getEnvDuration("GEOIP_WATCH_INTERVAL", time.Minute), "how often to check the database files for updates, 0 to disable (GEOIP_WATCH_INTERVAL)")
+	if err := fs.Parse(args); err != nil {
+		panic(err)
+	}
+
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		panic("port must be a number between 1 and 65535")
+	}
+	if cfg.CityDBPath == "" {
+		panic("city-db must not be empty")
+	}
+	if cfg.WatchInterval < 0 {
+		panic("watch-interval must not be negative")
+	}
+
+	cfg.Languages = splitList(languages)
+	return cfg
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic("environment variable " + key + " must be a duration (e.g. 1m)")
+	}
+	return d
+}
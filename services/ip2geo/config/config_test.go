@@ -0,0 +1,93 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var envKeys = []string{"PORT", "GEOIP_CITY_DB_PATH", "GEOIP_ASN_DB_PATH", "GEOIP_LANGUAGES", "GEOIP_WATCH_INTERVAL"}
+
+// loadWith runs load with only env set and a fresh flag set parsing args.
+func loadWith(t *testing.T, env map[string]string, args ...string) *Config {
+	t.Helper()
+	for _, key := range envKeys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	fs := flag.NewFlagSet("ip2geo", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return load(fs, args)
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	got := loadWith(t, nil)
+	want := &Config{
+		Port:          "50050",
+		CityDBPath:    "./db/GeoLite2-City.mmdb",
+		Languages:     []string{"en"},
+		WatchInterval: time.Minute,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLoad_FlagsOverrideEnvironment(t *testing.T) {
+	got := loadWith(t, map[string]string{
+		"PORT":                 "6000",
+		"GEOIP_CITY_DB_PATH":   "/env/city.mmdb",
+		"GEOIP_ASN_DB_PATH":    "/env/asn.mmdb",
+		"GEOIP_LANGUAGES":      " de, ,fr ",
+		"GEOIP_WATCH_INTERVAL": "0",
+	}, "-port", "7000", "-city-db", "/flag/city.mmdb")
+	want := &Config{
+		Port:       "7000",
+		CityDBPath: "/flag/city.mmdb",
+		ASNDBPath:  "/env/asn.mmdb",
+		Languages:  []string{"de", "fr"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestLoad_RejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "env interval not a duration", env: map[string]string{"GEOIP_WATCH_INTERVAL": "5"}, wantErr: "GEOIP_WATCH_INTERVAL must be a duration"},
+		{name: "flag interval not a duration", args: []string{"-watch-interval", "soon"}, wantErr: "invalid value"},
+		{name: "negative interval", args: []string{"-watch-interval", "-1m"}, wantErr: "watch-interval must not be negative"},
+		{name: "port not a number", env: map[string]string{"PORT": "grpc"}, wantErr: "port must be a number"},
+		{name: "port out of range", args: []string{"-port", "70000"}, wantErr: "port must be a number"},
+		{name: "empty city database", env: map[string]string{"GEOIP_CITY_DB_PATH": ""}, wantErr: "city-db must not be empty"},
+		{name: "unknown flag", args: []string{"-db", "x"}, wantErr: "flag provided but not defined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Fatal("load should panic")
+				}
+				if msg := fmt.Sprint(r); !strings.Contains(msg, tt.wantErr) {
+					t.Fatalf("panic %q, want it to contain %q", msg, tt.wantErr)
+				}
+			}()
+			loadWith(t, tt.env, tt.args...)
+		})
+	}
+}
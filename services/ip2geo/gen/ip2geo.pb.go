@@ -66,9 +66,22 @@ func (x *IpRequest) GetIp() string {
 }
 
 type GeoResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Country       string                 `protobuf:"bytes,1,opt,name=country,proto3" json:"country,omitempty"`
-	State         string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Country            string                 `protobuf:"bytes,1,opt,name=country,proto3" json:"country,omitempty"`
+	State              string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	CountryIsoCode     string                 `protobuf:"bytes,3,opt,name=country_iso_code,json=countryIsoCode,proto3" json:"country_iso_code,omitempty"`
+	SubdivisionIsoCode string                 `protobuf:"bytes,4,opt,name=subdivision_iso_code,json=subdivisionIsoCode,proto3" json:"subdivision_iso_code,omitempty"`
+	City               string                 `protobuf:"bytes,5,opt,name=city,proto3" json:"city,omitempty"`
+	Continent          string                 `protobuf:"bytes,6,opt,name=continent,proto3" json:"continent,omitempty"`
+	Latitude           float64                `protobuf:"fixed64,7,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude          float64                `protobuf:"fixed64,8,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	// Radius in kilometres around latitude/longitude that the address is likely within.
+	AccuracyRadius uint32 `protobuf:"varint,9,opt,name=accuracy_radius,json=accuracyRadius,proto3" json:"accuracy_radius,omitempty"`
+	TimeZone       string `protobuf:"bytes,10,opt,name=time_zone,json=timeZone,proto3" json:"time_zone,omitempty"`
+	PostalCode     string `protobuf:"bytes,11,opt,name=postal_code,json=postalCode,proto3" json:"postal_code,omitempty"`
+	// Autonomous system number and organization, set only when an ASN database is loaded.
+	Asn           uint32 `protobuf:"varint,12,opt,name=asn,proto3" json:"asn,omitempty"`
+	Organization  string `protobuf:"bytes,13,opt,name=organization,proto3" json:"organization,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -117,18 +130,410 @@ func (x *GeoResponse) GetState() string {
 	return ""
 }
 
+func (x *GeoResponse) GetCountryIsoCode() string {
+	if x != nil {
+		return x.CountryIsoCode
+	}
+	return ""
+}
+
+func (x *GeoResponse) GetSubdivisionIsoCode() string {
+	if x != nil {
+		return x.SubdivisionIsoCode
+	}
+	return ""
+}
+
+func (x *GeoResponse) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *GeoResponse) GetContinent() string {
+	if x != nil {
+		return x.Continent
+	}
+	return ""
+}
+
+func (x *GeoResponse) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *GeoResponse) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *GeoResponse) GetAccuracyRadius() uint32 {
+	if x != nil {
+		return x.AccuracyRadius
+	}
+	return 0
+}
+
+func (x *GeoResponse) GetTimeZone() string {
+	if x != nil {
+		return x.TimeZone
+	}
+	return ""
+}
+
+func (x *GeoResponse) GetPostalCode() string {
+	if x != nil {
+		return x.PostalCode
+	}
+	return ""
+}
+
+func (x *GeoResponse) GetAsn() uint32 {
+	if x != nil {
+		return x.Asn
+	}
+	return 0
+}
+
+func (x *GeoResponse) GetOrganization() string {
+	if x != nil {
+		return x.Organization
+	}
+	return ""
+}
+
+type IpBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      []*IpRequest           `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IpBatchRequest) Reset() {
+	*x = IpBatchRequest{}
+	mi := &file_ip2geo_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IpBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IpBatchRequest) ProtoMessage() {}
+
+func (x *IpBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ip2geo_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IpBatchRequest.ProtoReflect.Descriptor instead.
+func (*IpBatchRequest) Descriptor() ([]byte, []int) {
+	return file_ip2geo_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *IpBatchRequest) GetRequests() []*IpRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type GeoBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*GeoResponse         `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GeoBatchResponse) Reset() {
+	*x = GeoBatchResponse{}
+	mi := &file_ip2geo_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GeoBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeoBatchResponse) ProtoMessage() {}
+
+func (x *GeoBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ip2geo_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeoBatchResponse.ProtoReflect.Descriptor instead.
+func (*GeoBatchResponse) Descriptor() ([]byte, []int) {
+	return file_ip2geo_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GeoBatchResponse) GetResults() []*GeoResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type InfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoRequest) Reset() {
+	*x = InfoRequest{}
+	mi := &file_ip2geo_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoRequest) ProtoMessage() {}
+
+func (x *InfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ip2geo_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoRequest.ProtoReflect.Descriptor instead.
+func (*InfoRequest) Descriptor() ([]byte, []int) {
+	return file_ip2geo_proto_rawDescGZIP(), []int{4}
+}
+
+type ReloadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReloadRequest) Reset() {
+	*x = ReloadRequest{}
+	mi := &file_ip2geo_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReloadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReloadRequest) ProtoMessage() {}
+
+func (x *ReloadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_ip2geo_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReloadRequest.ProtoReflect.Descriptor instead.
+func (*ReloadRequest) Descriptor() ([]byte, []int) {
+	return file_ip2geo_proto_rawDescGZIP(), []int{5}
+}
+
+type DatabaseInfo struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Path         string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	DatabaseType string                 `protobuf:"bytes,2,opt,name=database_type,json=databaseType,proto3" json:"database_type,omitempty"`
+	// Unix time (seconds) the database was built by MaxMind.
+	BuildEpoch uint64 `protobuf:"varint,3,opt,name=build_epoch,json=buildEpoch,proto3" json:"build_epoch,omitempty"`
+	// Unix time (seconds) the database was loaded by this process.
+	LoadedAt      int64 `protobuf:"varint,4,opt,name=loaded_at,json=loadedAt,proto3" json:"loaded_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DatabaseInfo) Reset() {
+	*x = DatabaseInfo{}
+	mi := &file_ip2geo_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseInfo) ProtoMessage() {}
+
+func (x *DatabaseInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_ip2geo_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseInfo.ProtoReflect.Descriptor instead.
+func (*DatabaseInfo) Descriptor() ([]byte, []int) {
+	return file_ip2geo_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DatabaseInfo) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *DatabaseInfo) GetDatabaseType() string {
+	if x != nil {
+		return x.DatabaseType
+	}
+	return ""
+}
+
+func (x *DatabaseInfo) GetBuildEpoch() uint64 {
+	if x != nil {
+		return x.BuildEpoch
+	}
+	return 0
+}
+
+func (x *DatabaseInfo) GetLoadedAt() int64 {
+	if x != nil {
+		return x.LoadedAt
+	}
+	return 0
+}
+
+type InfoResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	City  *DatabaseInfo          `protobuf:"bytes,1,opt,name=city,proto3" json:"city,omitempty"`
+	// Unset when no ASN database is configured.
+	Asn           *DatabaseInfo `protobuf:"bytes,2,opt,name=asn,proto3" json:"asn,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoResponse) Reset() {
+	*x = InfoResponse{}
+	mi := &file_ip2geo_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoResponse) ProtoMessage() {}
+
+func (x *InfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_ip2geo_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoResponse.ProtoReflect.Descriptor instead.
+func (*InfoResponse) Descriptor() ([]byte, []int) {
+	return file_ip2geo_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *InfoResponse) GetCity() *DatabaseInfo {
+	if x != nil {
+		return x.City
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetAsn() *DatabaseInfo {
+	if x != nil {
+		return x.Asn
+	}
+	return nil
+}
+
 var File_ip2geo_proto protoreflect.FileDescriptor
 
 const file_ip2geo_proto_rawDesc = "" +
 	"\n" +
 	"\fip2geo.proto\x12\x06ip2geo\"\x1b\n" +
 	"\tIpRequest\x12\x0e\n" +
-	"\x02ip\x18\x01 \x01(\tR\x02ip\"=\n" +
+	"\x02ip\x18\x01 \x01(\tR\x02ip\"\xa2\x03\n" +
 	"\vGeoResponse\x12\x18\n" +
 	"\acountry\x18\x01 \x01(\tR\acountry\x12\x14\n" +
-	"\x05state\x18\x02 \x01(\tR\x05state2A\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12(\n" +
+	"\x10country_iso_code\x18\x03 \x01(\tR\x0ecountryIsoCode\x120\n" +
+	"\x14subdivision_iso_code\x18\x04 \x01(\tR\x12subdivisionIsoCode\x12\x12\n" +
+	"\x04city\x18\x05 \x01(\tR\x04city\x12\x1c\n" +
+	"\tcontinent\x18\x06 \x01(\tR\tcontinent\x12\x1a\n" +
+	"\blatitude\x18\a \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\b \x01(\x01R\tlongitude\x12'\n" +
+	"\x0faccuracy_radius\x18\t \x01(\rR\x0eaccuracyRadius\x12\x1b\n" +
+	"\ttime_zone\x18\n" +
+	" \x01(\tR\btimeZone\x12\x1f\n" +
+	"\vpostal_code\x18\v \x01(\tR\n" +
+	"postalCode\x12\x10\n" +
+	"\x03asn\x18\f \x01(\rR\x03asn\x12\"\n" +
+	"\forganization\x18\r \x01(\tR\forganization\"?\n" +
+	"\x0eIpBatchRequest\x12-\n" +
+	"\brequests\x18\x01 \x03(\v2\x11.ip2geo.IpRequestR\brequests\"A\n" +
+	"\x10GeoBatchResponse\x12-\n" +
+	"\aresults\x18\x01 \x03(\v2\x13.ip2geo.GeoResponseR\aresults\"\r\n" +
+	"\vInfoRequest\"\x0f\n" +
+	"\rReloadRequest\"\x85\x01\n" +
+	"\fDatabaseInfo\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12#\n" +
+	"\rdatabase_type\x18\x02 \x01(\tR\fdatabaseType\x12\x1f\n" +
+	"\vbuild_epoch\x18\x03 \x01(\x04R\n" +
+	"buildEpoch\x12\x1b\n" +
+	"\tloaded_at\x18\x04 \x01(\x03R\bloadedAt\"`\n" +
+	"\fInfoResponse\x12(\n" +
+	"\x04city\x18\x01 \x01(\v2\x14.ip2geo.DatabaseInfoR\x04city\x12&\n" +
+	"\x03asn\x18\x02 \x01(\v2\x14.ip2geo.DatabaseInfoR\x03asn2\xa8\x02\n" +
 	"\rIp2GeoService\x120\n" +
-	"\x06Lookup\x12\x11.ip2geo.IpRequest\x1a\x13.ip2geo.GeoResponseB\n" +
+	"\x06Lookup\x12\x11.ip2geo.IpRequest\x1a\x13.ip2geo.GeoResponse\x12?\n" +
+	"\vBatchLookup\x12\x16.ip2geo.IpBatchRequest\x1a\x18.ip2geo.GeoBatchResponse\x12:\n" +
+	"\fStreamLookup\x12\x11.ip2geo.IpRequest\x1a\x13.ip2geo.GeoResponse(\x010\x01\x121\n" +
+	"\x04Info\x12\x13.ip2geo.InfoRequest\x1a\x14.ip2geo.InfoResponse\x125\n" +
+	"\x06Reload\x12\x15.ip2geo.ReloadRequest\x1a\x14.ip2geo.InfoResponseB\n" +
 	"Z\b./../genb\x06proto3"
 
 var (
@@ -143,19 +548,37 @@ func file_ip2geo_proto_rawDescGZIP() []byte {
 	return file_ip2geo_proto_rawDescData
 }
 
-var file_ip2geo_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_ip2geo_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_ip2geo_proto_goTypes = []any{
-	(*IpRequest)(nil),   // 0: ip2geo.IpRequest
-	(*GeoResponse)(nil), // 1: ip2geo.GeoResponse
+	(*IpRequest)(nil),        // 0: ip2geo.IpRequest
+	(*GeoResponse)(nil),      // 1: ip2geo.GeoResponse
+	(*IpBatchRequest)(nil),   // 2: ip2geo.IpBatchRequest
+	(*GeoBatchResponse)(nil), // 3: ip2geo.GeoBatchResponse
+	(*InfoRequest)(nil),      // 4: ip2geo.InfoRequest
+	(*ReloadRequest)(nil),    // 5: ip2geo.ReloadRequest
+	(*DatabaseInfo)(nil),     // 6: ip2geo.DatabaseInfo
+	(*InfoResponse)(nil),     // 7: ip2geo.InfoResponse
 }
 var file_ip2geo_proto_depIdxs = []int32{
-	0, // 0: ip2geo.Ip2GeoService.Lookup:input_type -> ip2geo.IpRequest
-	1, // 1: ip2geo.Ip2GeoService.Lookup:output_type -> ip2geo.GeoResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0, // 0: ip2geo.IpBatchRequest.requests:type_name -> ip2geo.IpRequest
+	1, // 1: ip2geo.GeoBatchResponse.results:type_name -> ip2geo.GeoResponse
+	6, // 2: ip2geo.InfoResponse.city:type_name -> ip2geo.DatabaseInfo
+	6, // 3: ip2geo.InfoResponse.asn:type_name -> ip2geo.DatabaseInfo
+	0, // 4: ip2geo.Ip2GeoService.Lookup:input_type -> ip2geo.IpRequest
+	2, // 5: ip2geo.Ip2GeoService.BatchLookup:input_type -> ip2geo.IpBatchRequest
+	0, // 6: ip2geo.Ip2GeoService.StreamLookup:input_type -> ip2geo.IpRequest
+	4, // 7: ip2geo.Ip2GeoService.Info:input_type -> ip2geo.InfoRequest
+	5, // 8: ip2geo.Ip2GeoService.Reload:input_type -> ip2geo.ReloadRequest
+	1, // 9: ip2geo.Ip2GeoService.Lookup:output_type -> ip2geo.GeoResponse
+	3, // 10: ip2geo.Ip2GeoService.BatchLookup:output_type -> ip2geo.GeoBatchResponse
+	1, // 11: ip2geo.Ip2GeoService.StreamLookup:output_type -> ip2geo.GeoResponse
+	7, // 12: ip2geo.Ip2GeoService.Info:output_type -> ip2geo.InfoResponse
+	7, // 13: ip2geo.Ip2GeoService.Reload:output_type -> ip2geo.InfoResponse
+	9, // [9:14] is the sub-list for method output_type
+	4, // [4:9] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_ip2geo_proto_init() }
@@ -169,7 +592,7 @@ func file_ip2geo_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_ip2geo_proto_rawDesc), len(file_ip2geo_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
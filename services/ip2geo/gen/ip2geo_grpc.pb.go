@@ -19,14 +19,31 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Ip2GeoService_Lookup_FullMethodName = "/ip2geo.Ip2GeoService/Lookup"
+	Ip2GeoService_Lookup_FullMethodName       = "/ip2geo.Ip2GeoService/Lookup"
+	Ip2GeoService_BatchLookup_FullMethodName  = "/ip2geo.Ip2GeoService/BatchLookup"
+	Ip2GeoService_StreamLookup_FullMethodName = "/ip2geo.Ip2GeoService/StreamLookup"
+	Ip2GeoService_Info_FullMethodName         = "/ip2geo.Ip2GeoService/Info"
+	Ip2GeoService_Reload_FullMethodName       = "/ip2geo.Ip2GeoService/Reload"
 )
 
 // Ip2GeoServiceClient is the client API for Ip2GeoService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type Ip2GeoServiceClient interface {
+	// Lookup fails with INVALID_ARGUMENT for unparseable addresses and UNAVAILABLE when no
+	// database is loaded. Private, loopback, link-local and CGNAT addresses resolve to
+	// country/state "internal".
 	Lookup(ctx context.Context, in *IpRequest, opts ...grpc.CallOption) (*GeoResponse, error)
+	// BatchLookup resolves many IPs in one call. Results are in request order; unparseable
+	// addresses resolve to "unknown" rather than failing the batch.
+	BatchLookup(ctx context.Context, in *IpBatchRequest, opts ...grpc.CallOption) (*GeoBatchResponse, error)
+	// StreamLookup answers each request on the stream with one response, in order, with
+	// the same rules as BatchLookup.
+	StreamLookup(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[IpRequest, GeoResponse], error)
+	// Info reports which database files are loaded and when they were built.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+	// Reload reopens the database files and swaps them in without dropping lookups.
+	Reload(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*InfoResponse, error)
 }
 
 type ip2GeoServiceClient struct {
@@ -47,11 +64,67 @@ func (c *ip2GeoServiceClient) Lookup(ctx context.Context, in *IpRequest, opts ..
 	return out, nil
 }
 
+func (c *ip2GeoServiceClient) BatchLookup(ctx context.Context, in *IpBatchRequest, opts ...grpc.CallOption) (*GeoBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GeoBatchResponse)
+	err := c.cc.Invoke(ctx, Ip2GeoService_BatchLookup_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ip2GeoServiceClient) StreamLookup(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[IpRequest, GeoResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Ip2GeoService_ServiceDesc.Streams[0], Ip2GeoService_StreamLookup_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[IpRequest, GeoResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Ip2GeoService_StreamLookupClient = grpc.BidiStreamingClient[IpRequest, GeoResponse]
+
+func (c *ip2GeoServiceClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, Ip2GeoService_Info_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ip2GeoServiceClient) Reload(ctx context.Context, in *ReloadRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, Ip2GeoService_Reload_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Ip2GeoServiceServer is the server API for Ip2GeoService service.
 // All implementations must embed UnimplementedIp2GeoServiceServer
 // for forward compatibility.
 type Ip2GeoServiceServer interface {
+	// Lookup fails with INVALID_ARGUMENT for unparseable addresses and UNAVAILABLE when no
+	// database is loaded. Private, loopback, link-local and CGNAT addresses resolve to
+	// country/state "internal".
 	Lookup(context.Context, *IpRequest) (*GeoResponse, error)
+	// BatchLookup resolves many IPs in one call. Results are in request order; unparseable
+	// addresses resolve to "unknown" rather than failing the batch.
+	BatchLookup(context.Context, *IpBatchRequest) (*GeoBatchResponse, error)
+	// StreamLookup answers each request on the stream with one response, in order, with
+	// the same rules as BatchLookup.
+	StreamLookup(grpc.BidiStreamingServer[IpRequest, GeoResponse]) error
+	// Info reports which database files are loaded and when they were built.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+	// Reload reopens the database files and swaps them in without dropping lookups.
+	Reload(context.Context, *ReloadRequest) (*InfoResponse, error)
 	mustEmbedUnimplementedIp2GeoServiceServer()
 }
 
@@ -65,6 +138,18 @@ type UnimplementedIp2GeoServiceServer struct{}
 func (UnimplementedIp2GeoServiceServer) Lookup(context.Context, *IpRequest) (*GeoResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method Lookup not implemented")
 }
+func (UnimplementedIp2GeoServiceServer) BatchLookup(context.Context, *IpBatchRequest) (*GeoBatchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BatchLookup not implemented")
+}
+func (UnimplementedIp2GeoServiceServer) StreamLookup(grpc.BidiStreamingServer[IpRequest, GeoResponse]) error {
+	return status.Error(codes.Unimplemented, "method StreamLookup not implemented")
+}
+func (UnimplementedIp2GeoServiceServer) Info(context.Context, *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Info not implemented")
+}
+func (UnimplementedIp2GeoServiceServer) Reload(context.Context, *ReloadRequest) (*InfoResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Reload not implemented")
+}
 func (UnimplementedIp2GeoServiceServer) mustEmbedUnimplementedIp2GeoServiceServer() {}
 func (UnimplementedIp2GeoServiceServer) testEmbeddedByValue()                       {}
 
@@ -104,6 +189,67 @@ func _Ip2GeoService_Lookup_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Ip2GeoService_BatchLookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IpBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Ip2GeoServiceServer).BatchLookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ip2GeoService_BatchLookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Ip2GeoServiceServer).BatchLookup(ctx, req.(*IpBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ip2GeoService_StreamLookup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(Ip2GeoServiceServer).StreamLookup(&grpc.GenericServerStream[IpRequest, GeoResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Ip2GeoService_StreamLookupServer = grpc.BidiStreamingServer[IpRequest, GeoResponse]
+
+func _Ip2GeoService_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Ip2GeoServiceServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ip2GeoService_Info_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Ip2GeoServiceServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ip2GeoService_Reload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Ip2GeoServiceServer).Reload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ip2GeoService_Reload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Ip2GeoServiceServer).Reload(ctx, req.(*ReloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Ip2GeoService_ServiceDesc is the grpc.ServiceDesc for Ip2GeoService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,7 +261,26 @@ var Ip2GeoService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Lookup",
 			Handler:    _Ip2GeoService_Lookup_Handler,
 		},
+		{
+			MethodName: "BatchLookup",
+			Handler:    _Ip2GeoService_BatchLookup_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _Ip2GeoService_Info_Handler,
+		},
+		{
+			MethodName: "Reload",
+			Handler:    _Ip2GeoService_Reload_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLookup",
+			Handler:       _Ip2GeoService_StreamLookup_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "ip2geo.proto",
 }
@@ -1,53 +1,230 @@
 package geoip
 
 import (
+	"errors"
 	"log/slog"
 	"net"
+	"net/netip"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/oschwald/geoip2-golang"
 )
 
+// databases is one loaded generation of the City and (optional) ASN readers. Lookups hold
+// the read lock while using it, so a reload can wait for them before closing the files.
+type databases struct {
+	mu       sync.RWMutex
+	closed   bool
+	city     *geoip2.Reader
+	asn      *geoip2.Reader
+	loadedAt time.Time
+}
+
 var (
-	db   *geoip2.Reader
-	once sync.Once
+	current   atomic.Pointer[databases]
+	cityPath  string
+	asnPath   string
+	languages = []string{"en"}
+	reloadMu  sync.Mutex
 )
 
-func Init(dbPath string) error {
-	var err error
-	once.Do(func() {
-		absPath, _ := filepath.Abs(dbPath)
-		slog.Info("Loading GeoIP database", "path", absPath)
+// Init opens the GeoLite2-City database and, if asnDBPath is not empty, the GeoLite2-ASN
+// database used to fill in the autonomous system fields. The paths are remembered so
+// Reload can reopen them later. Place names are taken from the first of langs the
+// database has a name in, falling back to English.
+func Init(dbPath, asnDBPath string, langs []string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	cityPath, asnPath = dbPath, asnDBPath
+	languages = append(append([]string{}, langs...), "en")
+	return load()
+}
+
+// load opens the configured files and swaps them in. The caller must hold reloadMu.
+func load() error {
+	absPath, _ := filepath.Abs(cityPath)
+	slog.Info("Loading GeoIP database", "path", absPath)
+
+	city, err := geoip2.Open(cityPath)
+	if err != nil {
+		slog.Error("Failed to open GeoIP database", "error", err, "path", cityPath)
+		return err
+	}
 
-		db, err = geoip2.Open(dbPath)
+	var asn *geoip2.Reader
+	if asnPath != "" {
+		absPath, _ = filepath.Abs(asnPath)
+		slog.Info("Loading GeoIP ASN database", "path", absPath)
+
+		asn, err = geoip2.Open(asnPath)
 		if err != nil {
-			slog.Error("Failed to open GeoIP database", "error", err, "path", dbPath)
+			slog.Error("Failed to open GeoIP ASN database", "error", err, "path", asnPath)
+			city.Close()
+			return err
 		}
-	})
-	return err
+	}
+
+	next := &databases{city: city, asn: asn, loadedAt: time.Now()}
+	if prev := current.Swap(next); prev != nil {
+		// Let in-flight lookups finish on the old readers before unmapping them.
+		go prev.close()
+	}
+
+	slog.Info("GeoIP database loaded", "build_epoch", city.Metadata().BuildEpoch)
+	return nil
 }
 
-func Lookup(ipStr string) (country, state string) {
-	ip := net.ParseIP(ipStr)
-	record, err := db.City(ip)
-	if err != nil || record == nil {
-		return "unknown", "unknown"
+// acquire returns the current databases with the read lock held, or nil if none are
+// loaded. Callers must call release when done.
+func acquire() *databases {
+	for {
+		d := current.Load()
+		if d == nil {
+			return nil
+		}
+		d.mu.RLock()
+		if !d.closed {
+			return d
+		}
+		// Swapped out and closed between Load and RLock; pick up the new generation.
+		d.mu.RUnlock()
+	}
+}
+
+func (d *databases) release() {
+	d.mu.RUnlock()
+}
+
+func (d *databases) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	d.city.Close()
+	if d.asn != nil {
+		d.asn.Close()
 	}
+}
+
+// Location is everything we resolve for an IP address. Country and State are localized
+// place names and fall back to "unknown"; the other fields are empty when not known.
+type Location struct {
+	Country            string
+	State              string
+	CountryISOCode     string
+	SubdivisionISOCode string
+	City               string
+	Continent          string
+	Latitude           float64
+	Longitude          float64
+	AccuracyRadius     uint16
+	TimeZone           string
+	PostalCode         string
+	ASN                uint
+	Organization       string
+}
 
-	country = record.Country.Names["en"]
+var (
+	// ErrInvalidIP is returned by Lookup for input that is not an IPv4 or IPv6 address.
+	ErrInvalidIP = errors.New("geoip: invalid IP address")
+	// ErrNotLoaded is returned when no database has been opened.
+	ErrNotLoaded = errors.New("geoip: no database loaded")
+)
+
+// cgnat is the shared address space used by carrier-grade NAT (RFC 6598), which
+// netip.Addr.IsPrivate does not cover.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsInternal reports whether addr is a loopback, private, link-local, CGNAT or
+// unspecified address that can never be found in a GeoIP database.
+func IsInternal(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsUnspecified() ||
+		cgnat.Contains(addr)
+}
+
+// Lookup resolves ipStr. Internal addresses resolve to "internal" without touching the
+// database; addresses missing from the database resolve to "unknown".
+func Lookup(ipStr string) (Location, error) {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return Location{}, ErrInvalidIP
+	}
+	// Zones (fe80::1%eth0) are meaningless to the database.
+	addr = addr.WithZone("").Unmap()
+
+	if IsInternal(addr) {
+		return Location{Country: "internal", State: "internal"}, nil
+	}
+
+	d := acquire()
+	if d == nil {
+		return Location{}, ErrNotLoaded
+	}
+	defer d.release()
+
+	location := Location{Country: "unknown", State: "unknown"}
+
+	ip := net.IP(addr.AsSlice())
+	record, err := d.city.City(ip)
+	if err != nil {
+		return Location{}, err
+	}
+	if record == nil {
+		return location, nil
+	}
+
+	if name := localizedName(record.Country.Names); name != "" {
+		location.Country = name
+	}
+	location.CountryISOCode = record.Country.IsoCode
 
 	if len(record.Subdivisions) > 0 {
-		state = record.Subdivisions[0].Names["en"]
-	} else {
-		state = "unknown"
+		if name := localizedName(record.Subdivisions[0].Names); name != "" {
+			location.State = name
+		}
+		location.SubdivisionISOCode = record.Subdivisions[0].IsoCode
+	}
+
+	location.City = localizedName(record.City.Names)
+	location.Continent = localizedName(record.Continent.Names)
+	location.Latitude = record.Location.Latitude
+	location.Longitude = record.Location.Longitude
+	location.AccuracyRadius = record.Location.AccuracyRadius
+	location.TimeZone = record.Location.TimeZone
+	location.PostalCode = record.Postal.Code
+
+	if d.asn != nil {
+		if asn, err := d.asn.ASN(ip); err == nil {
+			location.ASN = asn.AutonomousSystemNumber
+			location.Organization = asn.AutonomousSystemOrganization
+		}
 	}
 
-	return country, state
+	return location, nil
+}
+
+// localizedName picks the first configured language present in names. languages is only
+// written by Init, before the server starts taking lookups.
+func localizedName(names map[string]string) string {
+	for _, lang := range languages {
+		if name := names[lang]; name != "" {
+			return name
+		}
+	}
+	return ""
 }
 
 func Close() {
-	if db != nil {
-		db.Close()
+	if d := current.Swap(nil); d != nil {
+		d.close()
 	}
 }
@@ -0,0 +1,178 @@
+package geoip
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestIsInternal(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.0.0.1", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"fd00::1", true},
+		{"169.254.1.1", true},
+		{"fe80::1", true},
+		{"100.63.255.255", false},
+		{"100.64.0.0", true},
+		{"100.127.255.255", true},
+		{"100.128.0.0", false},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:8.8.8.8", false},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := IsInternal(netip.MustParseAddr(tt.ip)); got != tt.want {
+				t.Fatalf("IsInternal(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	// No database is loaded in tests, so only addresses that never reach it resolve.
+	Close()
+
+	internal := Location{Country: "internal", State: "internal"}
+	tests := []struct {
+		ip      string
+		want    Location
+		wantErr error
+	}{
+		{ip: "127.0.0.1", want: internal},
+		{ip: "::ffff:10.0.0.1", want: internal},
+		{ip: "fe80::1%eth0", want: internal},
+		{ip: "100.64.0.1", want: internal},
+		{ip: "8.8.8.8", wantErr: ErrNotLoaded},
+		{ip: "2001:4860:4860::8888", wantErr: ErrNotLoaded},
+		{ip: "", wantErr: ErrInvalidIP},
+		{ip: "not-an-ip", wantErr: ErrInvalidIP},
+		{ip: "256.1.1.1", wantErr: ErrInvalidIP},
+		{ip: "8.8.8.8:53", wantErr: ErrInvalidIP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := Lookup(tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Lookup(%q) error = %v, want %v", tt.ip, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Lookup(%q) = %+v, want %+v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup_Fields(t *testing.T) {
+	if err := Init(geoIP2City, "testdata/GeoLite2-ASN-Test.mmdb", nil); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(Close)
+
+	london := Location{
+		Country: "United Kingdom", State: "England", CountryISOCode: "GB", SubdivisionISOCode: "ENG",
+		City: "London", Continent: "Europe", Latitude: 51.5142, Longitude: -0.0931, AccuracyRadius: 10,
+		TimeZone: "Europe/London",
+	}
+	tests := []struct {
+		name string
+		ip   string
+		want Location
+	}{
+		{name: "city and subdivision", ip: "81.2.69.142", want: london},
+		{name: "IPv4-mapped IPv6", ip: "::ffff:81.2.69.142", want: london},
+		{
+			name: "postal code",
+			ip:   "2.125.160.216",
+			want: Location{
+				Country: "United Kingdom", State: "England", CountryISOCode: "GB", SubdivisionISOCode: "ENG",
+				City: "Boxford", Continent: "Europe", Latitude: 51.75, Longitude: -1.25, AccuracyRadius: 100,
+				TimeZone: "Europe/London", PostalCode: "OX1",
+			},
+		},
+		{
+			name: "ASN without organization",
+			ip:   "216.160.83.56",
+			want: Location{
+				Country: "United States", State: "Washington", CountryISOCode: "US", SubdivisionISOCode: "WA",
+				City: "Milton", Continent: "North America", Latitude: 47.2513, Longitude: -122.3149, AccuracyRadius: 22,
+				TimeZone: "America/Los_Angeles", PostalCode: "98354", ASN: 209,
+			},
+		},
+		{
+			name: "ASN and organization",
+			ip:   "89.160.20.112",
+			want: Location{
+				Country: "Sweden", State: "Östergötland County", CountryISOCode: "SE", SubdivisionISOCode: "E",
+				City: "Linköping", Continent: "Europe", Latitude: 58.4167, Longitude: 15.6167, AccuracyRadius: 76,
+				TimeZone: "Europe/Stockholm", ASN: 29518, Organization: "Bredband2 AB",
+			},
+		},
+		{
+			name: "IPv6 country only",
+			ip:   "2001:218::1",
+			want: Location{
+				Country: "Japan", State: "unknown", CountryISOCode: "JP", Continent: "Asia",
+				Latitude: 35.68536, Longitude: 139.75309, AccuracyRadius: 100, TimeZone: "Asia/Tokyo",
+			},
+		},
+		{
+			name: "only in the ASN database",
+			ip:   "1.128.0.1",
+			want: Location{Country: "unknown", State: "unknown", ASN: 1221, Organization: "Telstra Pty Ltd"},
+		},
+		{name: "in neither database", ip: "1.1.1.1", want: Location{Country: "unknown", State: "unknown"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Lookup(tt.ip)
+			if err != nil {
+				t.Fatalf("Lookup(%q) error = %v", tt.ip, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Lookup(%q) = %+v\nwant %+v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup_Languages(t *testing.T) {
+	tests := []struct {
+		langs                     []string
+		country, state, continent string
+	}{
+		{[]string{"de"}, "Vereinigtes Königreich", "England", "Europa"},
+		// The test database has no Chinese name for England, so each name falls back on its own.
+		{[]string{"zh-CN", "de"}, "英国", "England", "欧洲"},
+		{[]string{"xx"}, "United Kingdom", "England", "Europe"},
+	}
+	t.Cleanup(Close)
+
+	for _, tt := range tests {
+		if err := Init(geoIP2City, "", tt.langs); err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		got, err := Lookup("81.2.69.142")
+		if err != nil {
+			t.Fatalf("Lookup error = %v", err)
+		}
+		if got.Country != tt.country || got.State != tt.state || got.Continent != tt.continent {
+			t.Errorf("langs %v: got %q, %q, %q; want %q, %q, %q", tt.langs, got.Country, got.State, got.Continent, tt.country, tt.state, tt.continent)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package geoip
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// DatabaseInfo describes one loaded database file.
+type DatabaseInfo struct {
+	Path         string
+	DatabaseType string
+	BuildEpoch   uint
+	LoadedAt     time.Time
+}
+
+// Info describes the loaded databases. ASN is nil when no ASN database is configured.
+type Info struct {
+	City DatabaseInfo
+	ASN  *DatabaseInfo
+}
+
+// Reload reopens the configured database files and atomically swaps them in. Lookups
+// already running finish on the previous readers. If the new files cannot be opened the
+// previous databases stay in use and the error is returned.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	return load()
+}
+
+// CurrentInfo reports the paths, types and build epochs of the loaded databases.
+func CurrentInfo() (Info, error) {
+	d := acquire()
+	if d == nil {
+		return Info{}, ErrNotLoaded
+	}
+	defer d.release()
+
+	reloadMu.Lock()
+	paths := [2]string{cityPath, asnPath}
+	reloadMu.Unlock()
+
+	meta := d.city.Metadata()
+	info := Info{
+		City: DatabaseInfo{
+			Path:         paths[0],
+			DatabaseType: meta.DatabaseType,
+			BuildEpoch:   meta.BuildEpoch,
+			LoadedAt:     d.loadedAt,
+		},
+	}
+
+	if d.asn != nil {
+		meta := d.asn.Metadata()
+		info.ASN = &DatabaseInfo{
+			Path:         paths[1],
+			DatabaseType: meta.DatabaseType,
+			BuildEpoch:   meta.BuildEpoch,
+			LoadedAt:     d.loadedAt,
+		}
+	}
+
+	return info, nil
+}
+
+// Watch polls the database files every interval and reloads when one of them has been
+// replaced or modified, e.g. by geoipupdate. It returns when ctx is cancelled.
+func Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := modTimes()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := modTimes()
+			if now == last {
+				continue
+			}
+
+			slog.Info("GeoIP database file changed, reloading")
+			if err := Reload(); err != nil {
+				// Keep the old mtimes so the next tick retries, e.g. after a partial copy.
+				slog.Error("GeoIP reload failed, keeping previous database", "error", err)
+				continue
+			}
+			last = now
+		}
+	}
+}
+
+func modTimes() [2]time.Time {
+	reloadMu.Lock()
+	paths := [2]string{cityPath, asnPath}
+	reloadMu.Unlock()
+
+	var times [2]time.Time
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+		if stat, err := os.Stat(path); err == nil {
+			times[i] = stat.ModTime()
+		}
+	}
+	return times
+}
@@ -0,0 +1,170 @@
+package geoip
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	geoIP2City  = "testdata/GeoIP2-City-Test.mmdb"
+	geoLiteCity = "testdata/GeoLite2-City-Test.mmdb"
+)
+
+// replaceFile puts data at path the way geoipupdate does, through a rename, so a reader
+// still mapping the old file is not affected. The mtime is moved forward by the size of
+// data, so successive replacements are seen by Watch even on coarse-grained file systems.
+func replaceFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Duration(len(data)) * time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// initCopy loads a copy of src, so the test can replace it, and closes it when the test
+// ends.
+func initCopy(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+	replaceFile(t, path, readFile(t, src))
+	if err := Init(path, "", nil); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(Close)
+	return path
+}
+
+func databaseType(t *testing.T) string {
+	t.Helper()
+	info, err := CurrentInfo()
+	if err != nil {
+		t.Fatalf("CurrentInfo: %v", err)
+	}
+	return info.City.DatabaseType
+}
+
+func isClosed(d *databases) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.closed
+}
+
+func TestReload_SwapsDatabase(t *testing.T) {
+	path := initCopy(t, geoIP2City)
+	if got := databaseType(t); got != "GeoIP2-City" {
+		t.Fatalf("database type = %q, want GeoIP2-City", got)
+	}
+
+	// A lookup in flight when the reload happens keeps the old readers open.
+	old := acquire()
+	replaceFile(t, path, readFile(t, geoLiteCity))
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := databaseType(t); got != "GeoLite2-City" {
+		t.Fatalf("database type after reload = %q, want GeoLite2-City", got)
+	}
+	if old.closed {
+		t.Fatal("old readers closed under a running lookup")
+	}
+	if record, err := old.city.City([]byte{81, 2, 69, 142}); err != nil || record.Country.IsoCode != "GB" {
+		t.Fatalf("running lookup on the old readers = %+v, %v", record, err)
+	}
+	old.release()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !isClosed(old) {
+		if time.Now().After(deadline) {
+			t.Fatal("old readers were never closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, err := Lookup("81.2.69.142"); err != nil || got.CountryISOCode != "GB" {
+		t.Fatalf("Lookup after reload = %+v, %v", got, err)
+	}
+}
+
+func TestReload_KeepsDatabaseOnBadFile(t *testing.T) {
+	city := readFile(t, geoIP2City)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"corrupt", []byte("not a MaxMind database")},
+		{"partial", city[:len(city)/2]},
+		{"empty", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := initCopy(t, geoIP2City)
+			before := current.Load()
+
+			replaceFile(t, path, tt.data)
+			if err := Reload(); err == nil {
+				t.Fatal("Reload should fail")
+			}
+			if current.Load() != before || isClosed(before) {
+				t.Fatal("a failed reload must keep the previous readers")
+			}
+			if got, err := Lookup("81.2.69.142"); err != nil || got.City != "London" {
+				t.Fatalf("Lookup after failed reload = %+v, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := initCopy(t, geoIP2City)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Watch(ctx, time.Millisecond)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for databaseType(t) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("database type = %q, want %q", databaseType(t), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// A half-copied file is skipped and picked up once it is complete.
+	lite := readFile(t, geoLiteCity)
+	before := current.Load()
+	replaceFile(t, path, lite[:len(lite)/2])
+	time.Sleep(50 * time.Millisecond)
+	if current.Load() != before {
+		t.Fatal("Watch swapped in a partial file")
+	}
+	replaceFile(t, path, lite)
+	waitFor("GeoLite2-City")
+}
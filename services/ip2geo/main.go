@@ -2,13 +2,21 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/wintkhantlin/url2short-ip2geo/config"
 	pb "github.com/wintkhantlin/url2short-ip2geo/gen"
 	"github.com/wintkhantlin/url2short-ip2geo/geoip"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type server struct {
@@ -16,20 +24,146 @@ type server struct {
 }
 
 func (s *server) Lookup(ctx context.Context, req *pb.IpRequest) (*pb.GeoResponse, error) {
-	country, state := geoip.Lookup(req.Ip)
+	location, err := geoip.Lookup(req.Ip)
+	if err != nil {
+		return nil, toStatus(err, req.Ip)
+	}
+	return toResponse(location), nil
+}
+
+func (s *server) BatchLookup(ctx context.Context, req *pb.IpBatchRequest) (*pb.GeoBatchResponse, error) {
+	results := make([]*pb.GeoResponse, 0, len(req.Requests))
+	for _, r := range req.Requests {
+		location, err := lookupInBatch(r.Ip)
+		if err != nil {
+			return nil, toStatus(err, r.Ip)
+		}
+		results = append(results, toResponse(location))
+	}
+
+	return &pb.GeoBatchResponse{Results: results}, nil
+}
+
+func (s *server) StreamLookup(stream pb.Ip2GeoService_StreamLookupServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		location, err := lookupInBatch(req.Ip)
+		if err != nil {
+			return toStatus(err, req.Ip)
+		}
+		if err := stream.Send(toResponse(location)); err != nil {
+			return err
+		}
+	}
+}
 
-	return &pb.GeoResponse{Country: country, State: state}, nil
+// lookupInBatch is Lookup for the batch and stream RPCs: one unparseable address must
+// not fail the others, so it resolves to "unknown" instead.
+func lookupInBatch(ip string) (geoip.Location, error) {
+	location, err := geoip.Lookup(ip)
+	if errors.Is(err, geoip.ErrInvalidIP) {
+		return geoip.Location{Country: "unknown", State: "unknown"}, nil
+	}
+	return location, err
+}
+
+func toStatus(err error, ip string) error {
+	switch {
+	case errors.Is(err, geoip.ErrInvalidIP):
+		return status.Errorf(codes.InvalidArgument, "invalid IP address %q", ip)
+	case errors.Is(err, geoip.ErrNotLoaded):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "lookup failed: %v", err)
+	}
+}
+
+func (s *server) Info(ctx context.Context, req *pb.InfoRequest) (*pb.InfoResponse, error) {
+	return info()
+}
+
+func (s *server) Reload(ctx context.Context, req *pb.ReloadRequest) (*pb.InfoResponse, error) {
+	if err := geoip.Reload(); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "reload failed, previous database still in use: %v", err)
+	}
+	return info()
+}
+
+func info() (*pb.InfoResponse, error) {
+	info, err := geoip.CurrentInfo()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	resp := &pb.InfoResponse{City: toDatabaseInfo(info.City)}
+	if info.ASN != nil {
+		resp.Asn = toDatabaseInfo(*info.ASN)
+	}
+	return resp, nil
+}
+
+func toDatabaseInfo(info geoip.DatabaseInfo) *pb.DatabaseInfo {
+	return &pb.DatabaseInfo{
+		Path:         info.Path,
+		DatabaseType: info.DatabaseType,
+		BuildEpoch:   uint64(info.BuildEpoch),
+		LoadedAt:     info.LoadedAt.Unix(),
+	}
+}
+
+func toResponse(location geoip.Location) *pb.GeoResponse {
+	return &pb.GeoResponse{
+		Country:            location.Country,
+		State:              location.State,
+		CountryIsoCode:     location.CountryISOCode,
+		SubdivisionIsoCode: location.SubdivisionISOCode,
+		City:               location.City,
+		Continent:          location.Continent,
+		Latitude:           location.Latitude,
+		Longitude:          location.Longitude,
+		AccuracyRadius:     uint32(location.AccuracyRadius),
+		TimeZone:           location.TimeZone,
+		PostalCode:         location.PostalCode,
+		Asn:                uint32(location.ASN),
+		Organization:       location.Organization,
+	}
 }
 
 func main() {
-	geoip.Init("./db/GeoLite2-City.mmdb")
+	cfg := config.Load()
 
-	port, is_port_env_ok := os.LookupEnv("PORT")
+	// Fail fast: without a database every lookup would come back unknown.
+	if err := geoip.Init(cfg.CityDBPath, cfg.ASNDBPath, cfg.Languages); err != nil {
+		slog.Error("Cannot start without a GeoIP database", "city_db", cfg.CityDBPath, "asn_db", cfg.ASNDBPath, "error", err)
+		os.Exit(1)
+	}
+	defer geoip.Close()
 
-	if !is_port_env_ok {
-		port = "50050"
+	// Pick up new MaxMind releases without a restart: on file change or SIGHUP.
+	if cfg.WatchInterval > 0 {
+		go geoip.Watch(context.Background(), cfg.WatchInterval)
 	}
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("Received SIGHUP, reloading GeoIP database")
+			if err := geoip.Reload(); err != nil {
+				slog.Error("GeoIP reload failed, keeping previous database", "error", err)
+			}
+		}
+	}()
+
+	port := cfg.Port
+
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 
 	if err != nil {
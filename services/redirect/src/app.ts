@@ -10,6 +10,13 @@ import logger from './logger.js';
 export const app = new Hono();
 app.use('*', honoLogger());
 
+// Ask Chromium browsers for the high-entropy client hints on later requests; the
+// User-Agent string they send is frozen and hides the OS version and device model.
+app.use('*', async (c, next) => {
+  await next();
+  c.header('Accept-CH', 'Sec-CH-UA-Platform-Version, Sec-CH-UA-Model');
+});
+
 const codeSchema = object({
   code: string().required().min(1).max(10),
 });
@@ -149,7 +156,20 @@ app.get('/:code', sValidator("param", codeSchema), async (c) => {
   const userAgent = c.req.header('user-agent') || 'unknown';
   const referer = c.req.header('referer') || '';
 
-  sendAnalyticsEvent({ code, ip, userAgent, referer });
+  const timestamp = new Date().toISOString();
+
+  sendAnalyticsEvent({
+    code,
+    ip,
+    userAgent,
+    referer,
+    timestamp,
+    secChUa: c.req.header('sec-ch-ua'),
+    secChUaPlatform: c.req.header('sec-ch-ua-platform'),
+    secChUaPlatformVersion: c.req.header('sec-ch-ua-platform-version'),
+    secChUaMobile: c.req.header('sec-ch-ua-mobile'),
+    secChUaModel: c.req.header('sec-ch-ua-model'),
+  });
 
   try {
     const cachedData = await redis.get(`alias:${code}`);
@@ -33,6 +33,12 @@ export const sendAnalyticsEvent = async (event: {
   ip: string;
   userAgent: string;
   referer?: string;
+  timestamp: string;
+  secChUa?: string;
+  secChUaPlatform?: string;
+  secChUaPlatformVersion?: string;
+  secChUaMobile?: string;
+  secChUaModel?: string;
 }) => {
   // Fire and forget
   producer.send({
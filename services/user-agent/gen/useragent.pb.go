@@ -22,10 +22,17 @@ const (
 )
 
 type UserAgentRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	UserAgent     string                 `protobuf:"bytes,1,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	UserAgent string                 `protobuf:"bytes,1,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	// Raw User-Agent Client Hints header values. When present they take precedence over
+	// what the (frozen) User-Agent string says.
+	SecChUa                string `protobuf:"bytes,2,opt,name=sec_ch_ua,json=secChUa,proto3" json:"sec_ch_ua,omitempty"`
+	SecChUaPlatform        string `protobuf:"bytes,3,opt,name=sec_ch_ua_platform,json=secChUaPlatform,proto3" json:"sec_ch_ua_platform,omitempty"`
+	SecChUaPlatformVersion string `protobuf:"bytes,4,opt,name=sec_ch_ua_platform_version,json=secChUaPlatformVersion,proto3" json:"sec_ch_ua_platform_version,omitempty"`
+	SecChUaMobile          string `protobuf:"bytes,5,opt,name=sec_ch_ua_mobile,json=secChUaMobile,proto3" json:"sec_ch_ua_mobile,omitempty"`
+	SecChUaModel           string `protobuf:"bytes,6,opt,name=sec_ch_ua_model,json=secChUaModel,proto3" json:"sec_ch_ua_model,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
 }
 
 func (x *UserAgentRequest) Reset() {
@@ -65,11 +72,57 @@ func (x *UserAgentRequest) GetUserAgent() string {
 	return ""
 }
 
+func (x *UserAgentRequest) GetSecChUa() string {
+	if x != nil {
+		return x.SecChUa
+	}
+	return ""
+}
+
+func (x *UserAgentRequest) GetSecChUaPlatform() string {
+	if x != nil {
+		return x.SecChUaPlatform
+	}
+	return ""
+}
+
+func (x *UserAgentRequest) GetSecChUaPlatformVersion() string {
+	if x != nil {
+		return x.SecChUaPlatformVersion
+	}
+	return ""
+}
+
+func (x *UserAgentRequest) GetSecChUaMobile() string {
+	if x != nil {
+		return x.SecChUaMobile
+	}
+	return ""
+}
+
+func (x *UserAgentRequest) GetSecChUaModel() string {
+	if x != nil {
+		return x.SecChUaModel
+	}
+	return ""
+}
+
 type UserAgentResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Browser       string                 `protobuf:"bytes,1,opt,name=browser,proto3" json:"browser,omitempty"`
-	Os            string                 `protobuf:"bytes,2,opt,name=os,proto3" json:"os,omitempty"`
-	Device        string                 `protobuf:"bytes,3,opt,name=device,proto3" json:"device,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Browser string                 `protobuf:"bytes,1,opt,name=browser,proto3" json:"browser,omitempty"`
+	Os      string                 `protobuf:"bytes,2,opt,name=os,proto3" json:"os,omitempty"`
+	Device  string                 `protobuf:"bytes,3,opt,name=device,proto3" json:"device,omitempty"`
+	// Crawlers, link-preview fetchers, uptime monitors and scripted HTTP clients.
+	IsBot bool `protobuf:"varint,4,opt,name=is_bot,json=isBot,proto3" json:"is_bot,omitempty"`
+	// One of link_preview, monitor, crawler, headless, http_client; empty for humans.
+	BotCategory string `protobuf:"bytes,5,opt,name=bot_category,json=botCategory,proto3" json:"bot_category,omitempty"`
+	BotName     string `protobuf:"bytes,6,opt,name=bot_name,json=botName,proto3" json:"bot_name,omitempty"`
+	// Dotted versions such as "17.4.1"; empty when the user agent doesn't carry one.
+	BrowserVersion string `protobuf:"bytes,7,opt,name=browser_version,json=browserVersion,proto3" json:"browser_version,omitempty"`
+	OsVersion      string `protobuf:"bytes,8,opt,name=os_version,json=osVersion,proto3" json:"os_version,omitempty"`
+	// Device vendor and model, e.g. "Apple" / "iPhone"; empty when unknown.
+	DeviceBrand   string `protobuf:"bytes,9,opt,name=device_brand,json=deviceBrand,proto3" json:"device_brand,omitempty"`
+	DeviceModel   string `protobuf:"bytes,10,opt,name=device_model,json=deviceModel,proto3" json:"device_model,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -125,20 +178,320 @@ func (x *UserAgentResponse) GetDevice() string {
 	return ""
 }
 
+func (x *UserAgentResponse) GetIsBot() bool {
+	if x != nil {
+		return x.IsBot
+	}
+	return false
+}
+
+func (x *UserAgentResponse) GetBotCategory() string {
+	if x != nil {
+		return x.BotCategory
+	}
+	return ""
+}
+
+func (x *UserAgentResponse) GetBotName() string {
+	if x != nil {
+		return x.BotName
+	}
+	return ""
+}
+
+func (x *UserAgentResponse) GetBrowserVersion() string {
+	if x != nil {
+		return x.BrowserVersion
+	}
+	return ""
+}
+
+func (x *UserAgentResponse) GetOsVersion() string {
+	if x != nil {
+		return x.OsVersion
+	}
+	return ""
+}
+
+func (x *UserAgentResponse) GetDeviceBrand() string {
+	if x != nil {
+		return x.DeviceBrand
+	}
+	return ""
+}
+
+func (x *UserAgentResponse) GetDeviceModel() string {
+	if x != nil {
+		return x.DeviceModel
+	}
+	return ""
+}
+
+type UserAgentBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Requests      []*UserAgentRequest    `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserAgentBatchRequest) Reset() {
+	*x = UserAgentBatchRequest{}
+	mi := &file_protobuf_useragent_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserAgentBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserAgentBatchRequest) ProtoMessage() {}
+
+func (x *UserAgentBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_useragent_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserAgentBatchRequest.ProtoReflect.Descriptor instead.
+func (*UserAgentBatchRequest) Descriptor() ([]byte, []int) {
+	return file_protobuf_useragent_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UserAgentBatchRequest) GetRequests() []*UserAgentRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type UserAgentBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*UserAgentResponse   `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserAgentBatchResponse) Reset() {
+	*x = UserAgentBatchResponse{}
+	mi := &file_protobuf_useragent_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserAgentBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserAgentBatchResponse) ProtoMessage() {}
+
+func (x *UserAgentBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_useragent_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserAgentBatchResponse.ProtoReflect.Descriptor instead.
+func (*UserAgentBatchResponse) Descriptor() ([]byte, []int) {
+	return file_protobuf_useragent_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UserAgentBatchResponse) GetResults() []*UserAgentResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type InfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoRequest) Reset() {
+	*x = InfoRequest{}
+	mi := &file_protobuf_useragent_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoRequest) ProtoMessage() {}
+
+func (x *InfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_useragent_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoRequest.ProtoReflect.Descriptor instead.
+func (*InfoRequest) Descriptor() ([]byte, []int) {
+	return file_protobuf_useragent_proto_rawDescGZIP(), []int{4}
+}
+
+type InfoResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Path of the regexes overlay file, or "builtin" when none is configured.
+	Source string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	// Hex SHA-256 of the overlay file; empty for "builtin".
+	Sha256 string `protobuf:"bytes,2,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Unix time (seconds) the rules were loaded by this process.
+	LoadedAt       int64  `protobuf:"varint,3,opt,name=loaded_at,json=loadedAt,proto3" json:"loaded_at,omitempty"`
+	UserAgentRules uint32 `protobuf:"varint,4,opt,name=user_agent_rules,json=userAgentRules,proto3" json:"user_agent_rules,omitempty"`
+	OsRules        uint32 `protobuf:"varint,5,opt,name=os_rules,json=osRules,proto3" json:"os_rules,omitempty"`
+	DeviceRules    uint32 `protobuf:"varint,6,opt,name=device_rules,json=deviceRules,proto3" json:"device_rules,omitempty"`
+	// How many of the rules above come from the overlay file.
+	OverlayRules  uint32 `protobuf:"varint,7,opt,name=overlay_rules,json=overlayRules,proto3" json:"overlay_rules,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoResponse) Reset() {
+	*x = InfoResponse{}
+	mi := &file_protobuf_useragent_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoResponse) ProtoMessage() {}
+
+func (x *InfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_protobuf_useragent_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoResponse.ProtoReflect.Descriptor instead.
+func (*InfoResponse) Descriptor() ([]byte, []int) {
+	return file_protobuf_useragent_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *InfoResponse) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *InfoResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *InfoResponse) GetLoadedAt() int64 {
+	if x != nil {
+		return x.LoadedAt
+	}
+	return 0
+}
+
+func (x *InfoResponse) GetUserAgentRules() uint32 {
+	if x != nil {
+		return x.UserAgentRules
+	}
+	return 0
+}
+
+func (x *InfoResponse) GetOsRules() uint32 {
+	if x != nil {
+		return x.OsRules
+	}
+	return 0
+}
+
+func (x *InfoResponse) GetDeviceRules() uint32 {
+	if x != nil {
+		return x.DeviceRules
+	}
+	return 0
+}
+
+func (x *InfoResponse) GetOverlayRules() uint32 {
+	if x != nil {
+		return x.OverlayRules
+	}
+	return 0
+}
+
 var File_protobuf_useragent_proto protoreflect.FileDescriptor
 
 const file_protobuf_useragent_proto_rawDesc = "" +
 	"\n" +
-	"\x18protobuf/useragent.proto\x12\tuseragent\"1\n" +
+	"\x18protobuf/useragent.proto\x12\tuseragent\"\x86\x02\n" +
 	"\x10UserAgentRequest\x12\x1d\n" +
 	"\n" +
-	"user_agent\x18\x01 \x01(\tR\tuserAgent\"U\n" +
+	"user_agent\x18\x01 \x01(\tR\tuserAgent\x12\x1a\n" +
+	"\tsec_ch_ua\x18\x02 \x01(\tR\asecChUa\x12+\n" +
+	"\x12sec_ch_ua_platform\x18\x03 \x01(\tR\x0fsecChUaPlatform\x12:\n" +
+	"\x1asec_ch_ua_platform_version\x18\x04 \x01(\tR\x16secChUaPlatformVersion\x12'\n" +
+	"\x10sec_ch_ua_mobile\x18\x05 \x01(\tR\rsecChUaMobile\x12%\n" +
+	"\x0fsec_ch_ua_model\x18\x06 \x01(\tR\fsecChUaModel\"\xb8\x02\n" +
 	"\x11UserAgentResponse\x12\x18\n" +
 	"\abrowser\x18\x01 \x01(\tR\abrowser\x12\x0e\n" +
 	"\x02os\x18\x02 \x01(\tR\x02os\x12\x16\n" +
-	"\x06device\x18\x03 \x01(\tR\x06device2V\n" +
+	"\x06device\x18\x03 \x01(\tR\x06device\x12\x15\n" +
+	"\x06is_bot\x18\x04 \x01(\bR\x05isBot\x12!\n" +
+	"\fbot_category\x18\x05 \x01(\tR\vbotCategory\x12\x19\n" +
+	"\bbot_name\x18\x06 \x01(\tR\abotName\x12'\n" +
+	"\x0fbrowser_version\x18\a \x01(\tR\x0ebrowserVersion\x12\x1d\n" +
+	"\n" +
+	"os_version\x18\b \x01(\tR\tosVersion\x12!\n" +
+	"\fdevice_brand\x18\t \x01(\tR\vdeviceBrand\x12!\n" +
+	"\fdevice_model\x18\n" +
+	" \x01(\tR\vdeviceModel\"P\n" +
+	"\x15UserAgentBatchRequest\x127\n" +
+	"\brequests\x18\x01 \x03(\v2\x1b.useragent.UserAgentRequestR\brequests\"P\n" +
+	"\x16UserAgentBatchResponse\x126\n" +
+	"\aresults\x18\x01 \x03(\v2\x1c.useragent.UserAgentResponseR\aresults\"\r\n" +
+	"\vInfoRequest\"\xe8\x01\n" +
+	"\fInfoResponse\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x16\n" +
+	"\x06sha256\x18\x02 \x01(\tR\x06sha256\x12\x1b\n" +
+	"\tloaded_at\x18\x03 \x01(\x03R\bloadedAt\x12(\n" +
+	"\x10user_agent_rules\x18\x04 \x01(\rR\x0euserAgentRules\x12\x19\n" +
+	"\bos_rules\x18\x05 \x01(\rR\aosRules\x12!\n" +
+	"\fdevice_rules\x18\x06 \x01(\rR\vdeviceRules\x12#\n" +
+	"\roverlay_rules\x18\a \x01(\rR\foverlayRules2\xb0\x02\n" +
 	"\x10UserAgentService\x12B\n" +
-	"\x05Parse\x12\x1b.useragent.UserAgentRequest\x1a\x1c.useragent.UserAgentResponseB1Z/github.com/wintkhantlin/url2short-useragent/genb\x06proto3"
+	"\x05Parse\x12\x1b.useragent.UserAgentRequest\x1a\x1c.useragent.UserAgentResponse\x12Q\n" +
+	"\n" +
+	"BatchParse\x12 .useragent.UserAgentBatchRequest\x1a!.useragent.UserAgentBatchResponse\x12L\n" +
+	"\vStreamParse\x12\x1b.useragent.UserAgentRequest\x1a\x1c.useragent.UserAgentResponse(\x010\x01\x127\n" +
+	"\x04Info\x12\x16.useragent.InfoRequest\x1a\x17.useragent.InfoResponseB1Z/github.com/wintkhantlin/url2short-useragent/genb\x06proto3"
 
 var (
 	file_protobuf_useragent_proto_rawDescOnce sync.Once
@@ -152,19 +505,31 @@ func file_protobuf_useragent_proto_rawDescGZIP() []byte {
 	return file_protobuf_useragent_proto_rawDescData
 }
 
-var file_protobuf_useragent_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_protobuf_useragent_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_protobuf_useragent_proto_goTypes = []any{
-	(*UserAgentRequest)(nil),  // 0: useragent.UserAgentRequest
-	(*UserAgentResponse)(nil), // 1: useragent.UserAgentResponse
+	(*UserAgentRequest)(nil),       // 0: useragent.UserAgentRequest
+	(*UserAgentResponse)(nil),      // 1: useragent.UserAgentResponse
+	(*UserAgentBatchRequest)(nil),  // 2: useragent.UserAgentBatchRequest
+	(*UserAgentBatchResponse)(nil), // 3: useragent.UserAgentBatchResponse
+	(*InfoRequest)(nil),            // 4: useragent.InfoRequest
+	(*InfoResponse)(nil),           // 5: useragent.InfoResponse
 }
 var file_protobuf_useragent_proto_depIdxs = []int32{
-	0, // 0: useragent.UserAgentService.Parse:input_type -> useragent.UserAgentRequest
-	1, // 1: useragent.UserAgentService.Parse:output_type -> useragent.UserAgentResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	0, // 0: useragent.UserAgentBatchRequest.requests:type_name -> useragent.UserAgentRequest
+	1, // 1: useragent.UserAgentBatchResponse.results:type_name -> useragent.UserAgentResponse
+	0, // 2: useragent.UserAgentService.Parse:input_type -> useragent.UserAgentRequest
+	2, // 3: useragent.UserAgentService.BatchParse:input_type -> useragent.UserAgentBatchRequest
+	0, // 4: useragent.UserAgentService.StreamParse:input_type -> useragent.UserAgentRequest
+	4, // 5: useragent.UserAgentService.Info:input_type -> useragent.InfoRequest
+	1, // 6: useragent.UserAgentService.Parse:output_type -> useragent.UserAgentResponse
+	3, // 7: useragent.UserAgentService.BatchParse:output_type -> useragent.UserAgentBatchResponse
+	1, // 8: useragent.UserAgentService.StreamParse:output_type -> useragent.UserAgentResponse
+	5, // 9: useragent.UserAgentService.Info:output_type -> useragent.InfoResponse
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
 }
 
 func init() { file_protobuf_useragent_proto_init() }
@@ -178,7 +543,7 @@ func file_protobuf_useragent_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_protobuf_useragent_proto_rawDesc), len(file_protobuf_useragent_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,7 +19,10 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	UserAgentService_Parse_FullMethodName = "/useragent.UserAgentService/Parse"
+	UserAgentService_Parse_FullMethodName       = "/useragent.UserAgentService/Parse"
+	UserAgentService_BatchParse_FullMethodName  = "/useragent.UserAgentService/BatchParse"
+	UserAgentService_StreamParse_FullMethodName = "/useragent.UserAgentService/StreamParse"
+	UserAgentService_Info_FullMethodName        = "/useragent.UserAgentService/Info"
 )
 
 // UserAgentServiceClient is the client API for UserAgentService service.
@@ -27,6 +30,12 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type UserAgentServiceClient interface {
 	Parse(ctx context.Context, in *UserAgentRequest, opts ...grpc.CallOption) (*UserAgentResponse, error)
+	// BatchParse parses many user agents in one call. Results are in request order.
+	BatchParse(ctx context.Context, in *UserAgentBatchRequest, opts ...grpc.CallOption) (*UserAgentBatchResponse, error)
+	// StreamParse answers each request on the stream with one response, in order.
+	StreamParse(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[UserAgentRequest, UserAgentResponse], error)
+	// Info reports which uap regexes are in use.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
 }
 
 type userAgentServiceClient struct {
@@ -47,11 +56,50 @@ func (c *userAgentServiceClient) Parse(ctx context.Context, in *UserAgentRequest
 	return out, nil
 }
 
+func (c *userAgentServiceClient) BatchParse(ctx context.Context, in *UserAgentBatchRequest, opts ...grpc.CallOption) (*UserAgentBatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UserAgentBatchResponse)
+	err := c.cc.Invoke(ctx, UserAgentService_BatchParse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userAgentServiceClient) StreamParse(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[UserAgentRequest, UserAgentResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UserAgentService_ServiceDesc.Streams[0], UserAgentService_StreamParse_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UserAgentRequest, UserAgentResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserAgentService_StreamParseClient = grpc.BidiStreamingClient[UserAgentRequest, UserAgentResponse]
+
+func (c *userAgentServiceClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, UserAgentService_Info_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UserAgentServiceServer is the server API for UserAgentService service.
 // All implementations must embed UnimplementedUserAgentServiceServer
 // for forward compatibility.
 type UserAgentServiceServer interface {
 	Parse(context.Context, *UserAgentRequest) (*UserAgentResponse, error)
+	// BatchParse parses many user agents in one call. Results are in request order.
+	BatchParse(context.Context, *UserAgentBatchRequest) (*UserAgentBatchResponse, error)
+	// StreamParse answers each request on the stream with one response, in order.
+	StreamParse(grpc.BidiStreamingServer[UserAgentRequest, UserAgentResponse]) error
+	// Info reports which uap regexes are in use.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
 	mustEmbedUnimplementedUserAgentServiceServer()
 }
 
@@ -65,6 +113,15 @@ type UnimplementedUserAgentServiceServer struct{}
 func (UnimplementedUserAgentServiceServer) Parse(context.Context, *UserAgentRequest) (*UserAgentResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method Parse not implemented")
 }
+func (UnimplementedUserAgentServiceServer) BatchParse(context.Context, *UserAgentBatchRequest) (*UserAgentBatchResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BatchParse not implemented")
+}
+func (UnimplementedUserAgentServiceServer) StreamParse(grpc.BidiStreamingServer[UserAgentRequest, UserAgentResponse]) error {
+	return status.Error(codes.Unimplemented, "method StreamParse not implemented")
+}
+func (UnimplementedUserAgentServiceServer) Info(context.Context, *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Info not implemented")
+}
 func (UnimplementedUserAgentServiceServer) mustEmbedUnimplementedUserAgentServiceServer() {}
 func (UnimplementedUserAgentServiceServer) testEmbeddedByValue()                          {}
 
@@ -104,6 +161,49 @@ func _UserAgentService_Parse_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UserAgentService_BatchParse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserAgentBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserAgentServiceServer).BatchParse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserAgentService_BatchParse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserAgentServiceServer).BatchParse(ctx, req.(*UserAgentBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserAgentService_StreamParse_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(UserAgentServiceServer).StreamParse(&grpc.GenericServerStream[UserAgentRequest, UserAgentResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserAgentService_StreamParseServer = grpc.BidiStreamingServer[UserAgentRequest, UserAgentResponse]
+
+func _UserAgentService_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserAgentServiceServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserAgentService_Info_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserAgentServiceServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UserAgentService_ServiceDesc is the grpc.ServiceDesc for UserAgentService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,7 +215,22 @@ var UserAgentService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Parse",
 			Handler:    _UserAgentService_Parse_Handler,
 		},
+		{
+			MethodName: "BatchParse",
+			Handler:    _UserAgentService_BatchParse_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _UserAgentService_Info_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamParse",
+			Handler:       _UserAgentService_StreamParse_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "protobuf/useragent.proto",
 }
@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/wintkhantlin/url2short-useragent/gen"
 	"github.com/wintkhantlin/url2short-useragent/pkg/parser"
@@ -16,20 +21,97 @@ type server struct {
 }
 
 func (s *server) Parse(ctx context.Context, req *gen.UserAgentRequest) (*gen.UserAgentResponse, error) {
-	info := parser.ParseUserAgent(req.UserAgent)
-	return &gen.UserAgentResponse{
-		Browser: info.Browser,
-		Os:      info.OS,
-		Device:  info.Device,
+	return parse(req), nil
+}
+
+func (s *server) BatchParse(ctx context.Context, req *gen.UserAgentBatchRequest) (*gen.UserAgentBatchResponse, error) {
+	results := make([]*gen.UserAgentResponse, 0, len(req.Requests))
+	for _, r := range req.Requests {
+		results = append(results, parse(r))
+	}
+	return &gen.UserAgentBatchResponse{Results: results}, nil
+}
+
+func (s *server) StreamParse(stream gen.UserAgentService_StreamParseServer) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(parse(req)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) Info(ctx context.Context, req *gen.InfoRequest) (*gen.InfoResponse, error) {
+	info := parser.CurrentRules()
+	return &gen.InfoResponse{
+		Source:         info.Source,
+		Sha256:         info.SHA256,
+		LoadedAt:       info.LoadedAt.Unix(),
+		UserAgentRules: uint32(info.UserAgentRules),
+		OsRules:        uint32(info.OSRules),
+		DeviceRules:    uint32(info.DeviceRules),
+		OverlayRules:   uint32(info.OverlayRules),
 	}, nil
 }
 
+func parse(req *gen.UserAgentRequest) *gen.UserAgentResponse {
+	info := parser.ParseWithClientHints(req.UserAgent, parser.ClientHints{
+		UA:              req.SecChUa,
+		Platform:        req.SecChUaPlatform,
+		PlatformVersion: req.SecChUaPlatformVersion,
+		Mobile:          req.SecChUaMobile,
+		Model:           req.SecChUaModel,
+	})
+	return &gen.UserAgentResponse{
+		Browser:        info.Browser,
+		Os:             info.OS,
+		Device:         info.Device,
+		IsBot:          info.IsBot,
+		BotCategory:    info.BotCategory,
+		BotName:        info.BotName,
+		BrowserVersion: info.BrowserVersion,
+		OsVersion:      info.OSVersion,
+		DeviceBrand:    info.DeviceBrand,
+		DeviceModel:    info.DeviceModel,
+	}
+}
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "50052"
 	}
 
+	// Optional overlay of uap regexes, e.g. for our own app's user agents. A broken file is
+	// a deployment mistake, so refuse to start rather than silently parse without it.
+	if path := os.Getenv("UAP_REGEXES_PATH"); path != "" {
+		if err := parser.Load(path); err != nil {
+			slog.Error("Cannot load uap regexes", "path", path, "error", err)
+			os.Exit(1)
+		}
+	}
+	slog.Info("Using uap regexes", "source", parser.CurrentRules().Source)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			slog.Info("Received SIGHUP, reloading uap regexes")
+			if err := parser.Reload(); err != nil {
+				slog.Error("uap regexes reload failed, keeping previous rules", "error", err)
+				continue
+			}
+			info := parser.CurrentRules()
+			slog.Info("Reloaded uap regexes", "source", info.Source, "sha256", info.SHA256, "overlay_rules", info.OverlayRules)
+		}
+	}()
+
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
 		panic(err)
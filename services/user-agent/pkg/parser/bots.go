@@ -0,0 +1,120 @@
+package parser
+
+import "strings"
+
+// Bot categories reported in UserAgentInfo.BotCategory.
+const (
+	BotCategoryLinkPreview = "link_preview"
+	BotCategoryMonitor     = "monitor"
+	BotCategoryCrawler     = "crawler"
+	BotCategoryHTTPClient  = "http_client"
+	BotCategoryHeadless    = "headless"
+)
+
+type botRule struct {
+	// token is matched case-insensitively anywhere in the user agent, or only at its
+	// start when it begins with "^".
+	token    string
+	name     string
+	category string
+}
+
+// botRules is checked in order and the first match wins, so more specific tokens must
+// come before generic ones (e.g. "slackbot-linkexpanding" before "slackbot"). Anything
+// uap-go itself classifies as a Spider but that is not listed here is reported as a
+// crawler under its uap family name.
+var botRules = []botRule{
+	// Link-preview fetchers: hit a short link once per share, never a human click.
+	{"slackbot-linkexpanding", "Slackbot", BotCategoryLinkPreview},
+	{"slackbot", "Slackbot", BotCategoryLinkPreview},
+	{"facebookexternalhit", "Facebook", BotCategoryLinkPreview},
+	{"facebookcatalog", "Facebook", BotCategoryLinkPreview},
+	{"twitterbot", "Twitterbot", BotCategoryLinkPreview},
+	{"linkedinbot", "LinkedInBot", BotCategoryLinkPreview},
+	{"discordbot", "Discordbot", BotCategoryLinkPreview},
+	{"telegrambot", "TelegramBot", BotCategoryLinkPreview},
+	// Only the preview fetcher starts with this; WhatsApp's in-app browser sends a
+	// regular Mozilla/5.0 user agent that may mention WhatsApp further in.
+	{"^whatsapp/", "WhatsApp", BotCategoryLinkPreview},
+	{"skypeuripreview", "Skype", BotCategoryLinkPreview},
+	{"microsoftpreview", "Microsoft Preview", BotCategoryLinkPreview},
+	{"redditbot", "Redditbot", BotCategoryLinkPreview},
+	{"embedly", "Embedly", BotCategoryLinkPreview},
+	{"iframely", "Iframely", BotCategoryLinkPreview},
+	// Mastodon servers fetch previews with "http.rb/x (Mastodon/y; +https://server/)";
+	// the apps' own user agents name Mastodon without the parenthesis.
+	{"(mastodon/", "Mastodon", BotCategoryLinkPreview},
+	{"bluesky cardyb/", "Bluesky", BotCategoryLinkPreview},
+	{"google-pagerenderer", "Google Page Renderer", BotCategoryLinkPreview},
+
+	// Uptime and synthetic monitoring.
+	{"uptimerobot", "UptimeRobot", BotCategoryMonitor},
+	{"pingdom", "Pingdom", BotCategoryMonitor},
+	{"statuscake", "StatusCake", BotCategoryMonitor},
+	{"site24x7", "Site24x7", BotCategoryMonitor},
+	{"betteruptime", "Better Uptime", BotCategoryMonitor},
+	{"better uptime", "Better Uptime", BotCategoryMonitor},
+	{"datadogsynthetics", "Datadog Synthetics", BotCategoryMonitor},
+	{"newrelicpinger", "New Relic", BotCategoryMonitor},
+	{"googlestackdrivermonitoring", "Google Cloud Monitoring", BotCategoryMonitor},
+	{"checkly", "Checkly", BotCategoryMonitor},
+	{"freshping", "Freshping", BotCategoryMonitor},
+	{"hetrixtools", "HetrixTools", BotCategoryMonitor},
+
+	// Search engines, SEO tools and AI crawlers.
+	{"googlebot", "Googlebot", BotCategoryCrawler},
+	{"adsbot-google", "AdsBot-Google", BotCategoryCrawler},
+	{"bingbot", "Bingbot", BotCategoryCrawler},
+	{"yandexbot", "YandexBot", BotCategoryCrawler},
+	{"duckduckbot", "DuckDuckBot", BotCategoryCrawler},
+	{"baiduspider", "Baiduspider", BotCategoryCrawler},
+	{"applebot", "Applebot", BotCategoryCrawler},
+	{"petalbot", "PetalBot", BotCategoryCrawler},
+	{"ahrefsbot", "AhrefsBot", BotCategoryCrawler},
+	{"semrushbot", "SemrushBot", BotCategoryCrawler},
+	{"mj12bot", "MJ12bot", BotCategoryCrawler},
+	{"dotbot", "DotBot", BotCategoryCrawler},
+	{"bytespider", "Bytespider", BotCategoryCrawler},
+	{"gptbot", "GPTBot", BotCategoryCrawler},
+	{"chatgpt-user", "ChatGPT-User", BotCategoryCrawler},
+	{"claudebot", "ClaudeBot", BotCategoryCrawler},
+	{"ccbot", "CCBot", BotCategoryCrawler},
+	{"perplexitybot", "PerplexityBot", BotCategoryCrawler},
+
+	// Headless browsers and scripted HTTP clients.
+	{"headlesschrome", "HeadlessChrome", BotCategoryHeadless},
+	{"phantomjs", "PhantomJS", BotCategoryHeadless},
+	{"curl/", "curl", BotCategoryHTTPClient},
+	{"wget/", "Wget", BotCategoryHTTPClient},
+	{"python-requests", "python-requests", BotCategoryHTTPClient},
+	{"python-urllib", "Python urllib", BotCategoryHTTPClient},
+	{"aiohttp", "aiohttp", BotCategoryHTTPClient},
+	{"go-http-client", "Go-http-client", BotCategoryHTTPClient},
+	{"node-fetch", "node-fetch", BotCategoryHTTPClient},
+	{"axios/", "axios", BotCategoryHTTPClient},
+	{"libwww-perl", "libwww-perl", BotCategoryHTTPClient},
+	{"apache-httpclient/", "Apache HttpClient", BotCategoryHTTPClient},
+	{"postmanruntime", "Postman", BotCategoryHTTPClient},
+	{"insomnia", "Insomnia", BotCategoryHTTPClient},
+}
+
+// detectBot returns the bot name and category for userAgent, using the rule list first
+// and uap-go's Spider device family as a fallback.
+func detectBot(userAgent, uaFamily, deviceFamily string) (name, category string, ok bool) {
+	lower := strings.ToLower(userAgent)
+	for _, rule := range botRules {
+		if prefix, ok := strings.CutPrefix(rule.token, "^"); ok {
+			if strings.HasPrefix(lower, prefix) {
+				return rule.name, rule.category, true
+			}
+		} else if strings.Contains(lower, rule.token) {
+			return rule.name, rule.category, true
+		}
+	}
+
+	if deviceFamily == "Spider" {
+		return uaFamily, BotCategoryCrawler, true
+	}
+
+	return "", "", false
+}
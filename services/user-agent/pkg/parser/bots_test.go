@@ -0,0 +1,59 @@
+package parser
+
+import "testing"
+
+func TestDetectBot(t *testing.T) {
+	tests := []struct {
+		name         string
+		userAgent    string
+		uaFamily     string
+		deviceFamily string
+		wantName     string
+		wantCategory string
+	}{
+		// Crawlers.
+		{name: "Googlebot", userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", wantName: "Googlebot", wantCategory: BotCategoryCrawler},
+		{name: "Bingbot", userAgent: "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm) Chrome/116.0.1938.76 Safari/537.36", wantName: "Bingbot", wantCategory: BotCategoryCrawler},
+		{name: "GPTBot", userAgent: "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.2; +https://openai.com/gptbot)", wantName: "GPTBot", wantCategory: BotCategoryCrawler},
+		{name: "unlisted uap spider", userAgent: "Mozilla/5.0 (compatible; SeznamBot/4.0; +https://o-seznam.cz/)", uaFamily: "SeznamBot", deviceFamily: "Spider", wantName: "SeznamBot", wantCategory: BotCategoryCrawler},
+
+		// Link-preview fetchers.
+		{name: "Slack link expanding", userAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", wantName: "Slackbot", wantCategory: BotCategoryLinkPreview},
+		{name: "Facebook", userAgent: "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", wantName: "Facebook", wantCategory: BotCategoryLinkPreview},
+		{name: "Twitterbot", userAgent: "Twitterbot/1.0", wantName: "Twitterbot", wantCategory: BotCategoryLinkPreview},
+		{name: "Discordbot", userAgent: "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", wantName: "Discordbot", wantCategory: BotCategoryLinkPreview},
+		{name: "WhatsApp preview", userAgent: "WhatsApp/2.23.20.0 A", wantName: "WhatsApp", wantCategory: BotCategoryLinkPreview},
+		{name: "WhatsApp preview on iOS", userAgent: "WhatsApp/2.24.6.77 i", wantName: "WhatsApp", wantCategory: BotCategoryLinkPreview},
+		{name: "Mastodon server", userAgent: "http.rb/5.1.1 (Mastodon/4.2.1; +https://mastodon.social/) Bot", wantName: "Mastodon", wantCategory: BotCategoryLinkPreview},
+		{name: "Bluesky card fetcher", userAgent: "Mozilla/5.0 (compatible; Bluesky Cardyb/1.1; +mailto:support@bsky.app)", wantName: "Bluesky", wantCategory: BotCategoryLinkPreview},
+
+		// Monitors and scripted clients.
+		{name: "UptimeRobot", userAgent: "Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)", wantName: "UptimeRobot", wantCategory: BotCategoryMonitor},
+		{name: "curl", userAgent: "curl/8.5.0", wantName: "curl", wantCategory: BotCategoryHTTPClient},
+		{name: "Apache HttpClient", userAgent: "Apache-HttpClient/4.5.14 (Java/17.0.9)", wantName: "Apache HttpClient", wantCategory: BotCategoryHTTPClient},
+		{name: "headless Chrome", userAgent: "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/124.0.0.0 Safari/537.36", wantName: "HeadlessChrome", wantCategory: BotCategoryHeadless},
+
+		// In-app browsers are people tapping the link.
+		{name: "WhatsApp in-app browser", userAgent: "Mozilla/5.0 (Linux; Android 13; SM-A536B Build/TP1A.220624.014; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.6099.230 Mobile Safari/537.36 WhatsApp/2.24.1.6"},
+		{name: "Facebook in-app browser", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBAV/455.0.0.39.109;FBBV/581346713;FBDV/iPhone15,2;FBMD/iPhone;FBSN/iOS;FBSV/17.4;FBSS/3;FBCR/;FBID/phone;FBLC/en_US;FBOP/80]"},
+		{name: "Instagram in-app browser", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 323.0.3.23.54 (iPhone15,2; iOS 17_4; en_US; en; scale=3.00; 1179x2556; 577210397)"},
+		{name: "Mastodon app browser", userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8 Build/AP2A.240805.005; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/127.0.6533.103 Mobile Safari/537.36 MastodonAndroid/2.5.3"},
+		{name: "Bluesky in-app browser", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Bluesky/1.90"},
+		{name: "LinkedIn in-app browser", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [LinkedInApp]/9.29.7466"},
+
+		// Ordinary browsers.
+		{name: "Chrome on Windows", userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", uaFamily: "Chrome", deviceFamily: "Other"},
+		{name: "Safari on iPhone", userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", uaFamily: "Mobile Safari", deviceFamily: "iPhone"},
+		{name: "app named after an HTTP client", userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) HttpClientDemo/1.0 Chrome/124.0.0.0 Safari/537.36", uaFamily: "Chrome", deviceFamily: "Other"},
+		{name: "Firefox on Linux", userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", uaFamily: "Firefox", deviceFamily: "Other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, category, ok := detectBot(tt.userAgent, tt.uaFamily, tt.deviceFamily)
+			if ok != (tt.wantName != "") || name != tt.wantName || category != tt.wantCategory {
+				t.Fatalf("detectBot() = %q, %q, %v; want %q, %q", name, category, ok, tt.wantName, tt.wantCategory)
+			}
+		})
+	}
+}
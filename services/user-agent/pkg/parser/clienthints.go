@@ -0,0 +1,194 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ClientHints holds the raw values of the User-Agent Client Hints request headers.
+// Chromium browsers freeze the OS version and device model in the User-Agent string,
+// so these are the only reliable source for them. Any field may be empty.
+type ClientHints struct {
+	UA              string // Sec-CH-UA
+	Platform        string // Sec-CH-UA-Platform
+	PlatformVersion string // Sec-CH-UA-Platform-Version
+	Mobile          string // Sec-CH-UA-Mobile
+	Model           string // Sec-CH-UA-Model
+}
+
+// ParseWithClientHints parses userAgent and overrides the result with whatever the
+// client hints say, since they describe the real browser, platform and device.
+func ParseWithClientHints(userAgent string, hints ClientHints) UserAgentInfo {
+	info := ParseUserAgent(userAgent)
+	applyClientHints(&info, hints)
+	return info
+}
+
+// platformNames maps Sec-CH-UA-Platform values to the uap-go OS families used elsewhere.
+var platformNames = map[string]string{
+	"macOS":     "Mac OS X",
+	"Windows":   "Windows",
+	"Android":   "Android",
+	"iOS":       "iOS",
+	"Linux":     "Linux",
+	"Chrome OS": "Chrome OS",
+	"Fuchsia":   "Fuchsia",
+}
+
+// brandNames maps Sec-CH-UA brands to uap-go browser families. Unlisted brands are
+// used as-is.
+var brandNames = map[string]string{
+	"Google Chrome":    "Chrome",
+	"Microsoft Edge":   "Edge",
+	"Opera":            "Opera",
+	"Opera GX":         "Opera",
+	"YaBrowser":        "Yandex Browser",
+	"Samsung Internet": "Samsung Internet",
+}
+
+func applyClientHints(info *UserAgentInfo, hints ClientHints) {
+	if brand, version := primaryBrand(hints.UA); brand != "" {
+		if name, ok := brandNames[brand]; ok {
+			brand = name
+		}
+		// Keep uap-go's more specific family ("Chrome Mobile") when it agrees with the
+		// brand, and its fuller version when the major matches.
+		if !strings.Contains(info.Browser, brand) {
+			info.Browser = brand
+			info.BrowserVersion = version
+		} else if version != "" && majorVersion(info.BrowserVersion) != version {
+			info.BrowserVersion = version
+		}
+	}
+
+	if platform := sfString(hints.Platform); platform != "" && platform != "Unknown" {
+		if name, ok := platformNames[platform]; ok {
+			platform = name
+		}
+		if info.OS != platform {
+			info.OS = platform
+			info.OSVersion = ""
+		}
+	}
+
+	if version := platformVersion(info.OS, sfString(hints.PlatformVersion)); version != "" {
+		info.OSVersion = version
+	}
+
+	if model := sfString(hints.Model); model != "" {
+		info.Device = model
+		info.DeviceModel = model
+		// Frozen Android user agents yield placeholder brands such as "Generic_Android".
+		if strings.HasPrefix(info.DeviceBrand, "Generic") {
+			info.DeviceBrand = ""
+		}
+	} else if strings.TrimSpace(hints.Mobile) == "?1" && (info.Device == "Other" || info.Device == "unknown") {
+		info.Device = "Generic Smartphone"
+	}
+}
+
+// primaryBrand picks the most specific brand from a Sec-CH-UA list such as
+// `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`, skipping GREASE
+// entries and only settling for "Chromium" when nothing else is listed.
+func primaryBrand(header string) (brand, version string) {
+	for _, item := range splitOutsideQuotes(header, ',') {
+		params := splitOutsideQuotes(item, ';')
+		// Brands are always quoted; anything else is a mangled header.
+		if !isQuoted(strings.TrimSpace(params[0])) {
+			continue
+		}
+		name := sfString(params[0])
+		if name == "" || isGreaseBrand(name) {
+			continue
+		}
+		var v string
+		for _, param := range params[1:] {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && key == "v" {
+				v = sfString(value)
+			}
+		}
+		if name == "Chromium" {
+			if brand == "" {
+				brand, version = name, v
+			}
+			continue
+		}
+		return name, v
+	}
+	return brand, version
+}
+
+// isGreaseBrand reports whether brand is one of Chromium's randomised placeholder brands
+// ("Not A(Brand", "Not/A)Brand", ...).
+func isGreaseBrand(brand string) bool {
+	lower := strings.ToLower(brand)
+	return strings.HasPrefix(lower, "not") && strings.Contains(lower, "brand")
+}
+
+// platformVersion turns a Sec-CH-UA-Platform-Version into the version uap-go would report.
+// Windows reports its UniversalApiContract version: 13 and up is Windows 11, 1-12 is
+// Windows 10, and 0.x (Windows 7/8) can't be told apart, so it is ignored.
+func platformVersion(os, version string) string {
+	if version == "" {
+		return ""
+	}
+	if os == "Windows" {
+		major, err := strconv.Atoi(majorVersion(version))
+		switch {
+		case err != nil || major == 0:
+			return ""
+		case major >= 13:
+			return "11"
+		default:
+			return "10"
+		}
+	}
+	// "14.0.0" -> "14", "14.4.1" stays as is.
+	for strings.HasSuffix(version, ".0") {
+		version = strings.TrimSuffix(version, ".0")
+	}
+	return version
+}
+
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+// sfString returns the value of a structured-field string ("macOS"), or the trimmed
+// input when it isn't quoted.
+func sfString(value string) string {
+	value = strings.TrimSpace(value)
+	if isQuoted(value) {
+		value = value[1 : len(value)-1]
+		value = strings.ReplaceAll(value, `\"`, `"`)
+		value = strings.ReplaceAll(value, `\\`, `\`)
+	}
+	return strings.TrimSpace(value)
+}
+
+func isQuoted(value string) bool {
+	return len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"'
+}
+
+// splitOutsideQuotes splits s on sep, ignoring separators inside quoted strings; GREASE
+// brands deliberately contain characters like ';' and '='.
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
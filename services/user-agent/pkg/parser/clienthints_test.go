@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPrimaryBrand(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantBrand   string
+		wantVersion string
+	}{
+		{"chrome with GREASE", `"Not)A;Brand";v="8", "Chromium";v="138", "Google Chrome";v="138"`, "Google Chrome", "138"},
+		{"GREASE last", `"Chromium";v="124", "Microsoft Edge";v="124", "Not-A.Brand";v="99"`, "Microsoft Edge", "124"},
+		{"GREASE with equals sign", `"Not=A?Brand";v="24", "Chromium";v="116"`, "Chromium", "116"},
+		{"only Chromium", `"Chromium";v="124", "Not_A Brand";v="8"`, "Chromium", "124"},
+		{"only GREASE", `"Not_A Brand";v="8"`, "", ""},
+		{"quoted comma", `"Brave, Beta";v="1", "Chromium";v="1"`, "Brave, Beta", "1"},
+		{"quoted semicolon", `"Chromium";v="120", "Weird;Name";v="5"`, "Weird;Name", "5"},
+		{"escaped quote", `"Say \"Hi\"";v="2"`, `Say "Hi"`, "2"},
+		{"no version", `"Google Chrome"`, "Google Chrome", ""},
+		{"empty version", `"Google Chrome";v=`, "Google Chrome", ""},
+		{"extra parameters", `"Google Chrome"; a=1; v="138"`, "Google Chrome", "138"},
+		{"empty", ``, "", ""},
+		{"only separators", `, ;,`, "", ""},
+		{"unquoted brand", `Chrome;v="138"`, "", ""},
+		{"unterminated quote", `"Google Chrome;v="138`, "", ""},
+		{"missing brand", `;v="138", "Firefox";v="1"`, "Firefox", "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			brand, version := primaryBrand(tt.header)
+			if brand != tt.wantBrand || version != tt.wantVersion {
+				t.Fatalf("primaryBrand(%s) = %q, %q; want %q, %q", tt.header, brand, version, tt.wantBrand, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSplitOutsideQuotes(t *testing.T) {
+	tests := []struct {
+		s    string
+		sep  byte
+		want []string
+	}{
+		{`a,b`, ',', []string{"a", "b"}},
+		{`"a,b",c`, ',', []string{`"a,b"`, "c"}},
+		{`"a\",b",c`, ',', []string{`"a\",b"`, "c"}},
+		{`"a;b";v="1"`, ';', []string{`"a;b"`, `v="1"`}},
+		{`a\,b`, ',', []string{`a\`, "b"}},
+		{`a,`, ',', []string{"a", ""}},
+		{``, ',', []string{""}},
+		{`"a,b`, ',', []string{`"a,b`}},
+	}
+
+	for _, tt := range tests {
+		if got := splitOutsideQuotes(tt.s, tt.sep); !slices.Equal(got, tt.want) {
+			t.Errorf("splitOutsideQuotes(%s, %q) = %q, want %q", tt.s, tt.sep, got, tt.want)
+		}
+	}
+}
+
+func TestPlatformVersion(t *testing.T) {
+	tests := []struct {
+		os, version, want string
+	}{
+		{"Windows", "15.0.0", "11"},
+		{"Windows", "13.0.0", "11"},
+		{"Windows", "12.0.0", "10"},
+		{"Windows", "1.0.0", "10"},
+		{"Windows", "0.3.0", ""},
+		{"Windows", "abc", ""},
+		{"Windows", "", ""},
+		{"Mac OS X", "14.0.0", "14"},
+		{"Mac OS X", "14.4.1", "14.4.1"},
+		{"Android", "14.0.0", "14"},
+		{"Android", "10.0", "10"},
+		{"Linux", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := platformVersion(tt.os, tt.version); got != tt.want {
+			t.Errorf("platformVersion(%q, %q) = %q, want %q", tt.os, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestSfString(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{`"macOS"`, "macOS"},
+		{` "Windows" `, "Windows"},
+		{`Linux`, "Linux"},
+		{`""`, ""},
+		{`"a\"b"`, `a"b`},
+		{`"a\\b"`, `a\b`},
+		{`"unterminated`, `"unterminated`},
+		{``, ""},
+	}
+
+	for _, tt := range tests {
+		if got := sfString(tt.value); got != tt.want {
+			t.Errorf("sfString(%s) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestApplyClientHints(t *testing.T) {
+	const chrome138 = `"Not)A;Brand";v="8", "Chromium";v="138", "Google Chrome";v="138"`
+
+	tests := []struct {
+		name  string
+		info  UserAgentInfo
+		hints ClientHints
+		want  UserAgentInfo
+	}{
+		{
+			name:  "no hints leave the user agent result alone",
+			info:  UserAgentInfo{Browser: "Chrome", BrowserVersion: "138.0.0.0", OS: "Windows", OSVersion: "10", Device: "Other"},
+			hints: ClientHints{},
+			want:  UserAgentInfo{Browser: "Chrome", BrowserVersion: "138.0.0.0", OS: "Windows", OSVersion: "10", Device: "Other"},
+		},
+		{
+			name:  "platform version unfreezes Windows 11",
+			info:  UserAgentInfo{Browser: "Chrome", BrowserVersion: "138.0.0.0", OS: "Windows", OSVersion: "10", Device: "Other"},
+			hints: ClientHints{UA: chrome138, Platform: `"Windows"`, PlatformVersion: `"15.0.0"`, Mobile: "?0"},
+			want:  UserAgentInfo{Browser: "Chrome", BrowserVersion: "138.0.0.0", OS: "Windows", OSVersion: "11", Device: "Other"},
+		},
+		{
+			name:  "brand overrides a different family",
+			info:  UserAgentInfo{Browser: "Chrome", BrowserVersion: "138.0.0.0", OS: "Windows", OSVersion: "10", Device: "Other"},
+			hints: ClientHints{UA: `"Chromium";v="138", "Microsoft Edge";v="138", "Not)A;Brand";v="8"`},
+			want:  UserAgentInfo{Browser: "Edge", BrowserVersion: "138", OS: "Windows", OSVersion: "10", Device: "Other"},
+		},
+		{
+			name:  "more specific family kept, newer major taken",
+			info:  UserAgentInfo{Browser: "Chrome Mobile", BrowserVersion: "120.0.6099.43", OS: "Android", OSVersion: "10", Device: "K"},
+			hints: ClientHints{UA: `"Google Chrome";v="124"`},
+			want:  UserAgentInfo{Browser: "Chrome Mobile", BrowserVersion: "124", OS: "Android", OSVersion: "10", Device: "K"},
+		},
+		{
+			name: "model and version replace a frozen Android user agent",
+			info: UserAgentInfo{Browser: "Chrome Mobile", BrowserVersion: "138.0.0.0", OS: "Android", OSVersion: "10", Device: "K", DeviceBrand: "Generic_Android", DeviceModel: "K"},
+			hints: ClientHints{
+				UA:              chrome138,
+				Platform:        `"Android"`,
+				PlatformVersion: `"14.0.0"`,
+				Mobile:          "?1",
+				Model:           `"Pixel 8"`,
+			},
+			want: UserAgentInfo{Browser: "Chrome Mobile", BrowserVersion: "138.0.0.0", OS: "Android", OSVersion: "14", Device: "Pixel 8", DeviceModel: "Pixel 8"},
+		},
+		{
+			name:  "different platform drops the user agent's OS version",
+			info:  UserAgentInfo{Browser: "Chrome", OS: "Linux", OSVersion: "5", Device: "Other"},
+			hints: ClientHints{Platform: `"Chrome OS"`},
+			want:  UserAgentInfo{Browser: "Chrome", OS: "Chrome OS", Device: "Other"},
+		},
+		{
+			name:  "unknown platform ignored",
+			info:  UserAgentInfo{Browser: "Chrome", OS: "Linux", OSVersion: "5", Device: "Other"},
+			hints: ClientHints{Platform: `"Unknown"`},
+			want:  UserAgentInfo{Browser: "Chrome", OS: "Linux", OSVersion: "5", Device: "Other"},
+		},
+		{
+			name:  "mobile hint without a model",
+			info:  UserAgentInfo{Browser: "Chrome", OS: "Android", Device: "Other"},
+			hints: ClientHints{Mobile: "?1"},
+			want:  UserAgentInfo{Browser: "Chrome", OS: "Android", Device: "Generic Smartphone"},
+		},
+		{
+			name:  "mobile hint keeps a known device",
+			info:  UserAgentInfo{Browser: "Chrome", OS: "Android", Device: "Samsung SM-S918B"},
+			hints: ClientHints{Mobile: "?1"},
+			want:  UserAgentInfo{Browser: "Chrome", OS: "Android", Device: "Samsung SM-S918B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := tt.info
+			applyClientHints(&info, tt.hints)
+			if info != tt.want {
+				t.Fatalf("got %+v\nwant %+v", info, tt.want)
+			}
+		})
+	}
+}
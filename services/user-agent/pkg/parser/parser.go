@@ -1,19 +1,19 @@
 package parser
 
-import (
-	"github.com/ua-parser/uap-go/uaparser"
-)
-
-var parser *uaparser.Parser
-
-func init() {
-	parser = uaparser.NewFromSaved()
-}
-
 type UserAgentInfo struct {
 	Browser string
 	OS      string
 	Device  string
+	// Versions are dotted ("17.4.1") and, like Brand and Model, empty when unknown.
+	BrowserVersion string
+	OSVersion      string
+	DeviceBrand    string
+	DeviceModel    string
+	// IsBot is set for crawlers, link-preview fetchers, monitors and scripted clients.
+	// BotName and BotCategory are empty for humans.
+	IsBot       bool
+	BotName     string
+	BotCategory string
 }
 
 func ParseUserAgent(userAgent string) UserAgentInfo {
@@ -25,12 +25,16 @@ func ParseUserAgent(userAgent string) UserAgentInfo {
 		}
 	}
 
-	client := parser.Parse(userAgent)
+	client := rules.Load().parser.Parse(userAgent)
 
 	info := UserAgentInfo{
-		Browser: client.UserAgent.Family,
-		OS:      client.Os.Family,
-		Device:  client.Device.Family,
+		Browser:        client.UserAgent.Family,
+		OS:             client.Os.Family,
+		Device:         client.Device.Family,
+		BrowserVersion: client.UserAgent.ToVersionString(),
+		OSVersion:      client.Os.ToVersionString(),
+		DeviceBrand:    client.Device.Brand,
+		DeviceModel:    client.Device.Model,
 	}
 
 	if info.Browser == "" {
@@ -43,5 +47,7 @@ func ParseUserAgent(userAgent string) UserAgentInfo {
 		info.Device = "unknown"
 	}
 
+	info.BotName, info.BotCategory, info.IsBot = detectBot(userAgent, client.UserAgent.Family, client.Device.Family)
+
 	return info
 }
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ua-parser/uap-go/uaparser"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceBuiltin is RulesInfo.Source when only the regexes compiled into uap-go are used.
+const SourceBuiltin = "builtin"
+
+// RulesInfo describes the active rule set.
+type RulesInfo struct {
+	// Source is the overlay file path, or SourceBuiltin.
+	Source string
+	// SHA256 is the hex digest of the overlay file; empty for SourceBuiltin.
+	SHA256   string
+	LoadedAt time.Time
+	// Rule counts including the overlay.
+	UserAgentRules int
+	OSRules        int
+	DeviceRules    int
+	// OverlayRules is how many of the rules above came from the overlay file.
+	OverlayRules int
+}
+
+type ruleSet struct {
+	parser *uaparser.Parser
+	info   RulesInfo
+}
+
+var (
+	// rules is swapped as a whole on reload, so a parse never sees a half-built set.
+	rules atomic.Pointer[ruleSet]
+
+	reloadMu    sync.Mutex
+	regexesPath string
+)
+
+func init() {
+	rs, err := loadRules("")
+	if err != nil {
+		// The built-in regexes are compiled into the binary; failing here is a bug.
+		panic(err)
+	}
+	rules.Store(rs)
+}
+
+// Load layers the uap-go regexes in path over the built-in ones and makes them active.
+// Overlay rules are tried first, so they can recognise in-house user agents or override
+// how existing ones are classified. An empty path selects the built-in rules.
+func Load(path string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	rs, err := loadRules(path)
+	if err != nil {
+		return err
+	}
+	regexesPath = path
+	rules.Store(rs)
+	return nil
+}
+
+// Reload re-reads the overlay file given to Load. On error the current rules stay active.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	rs, err := loadRules(regexesPath)
+	if err != nil {
+		return err
+	}
+	rules.Store(rs)
+	return nil
+}
+
+// CurrentRules reports which rule set is active.
+func CurrentRules() RulesInfo {
+	return rules.Load().info
+}
+
+func loadRules(path string) (*ruleSet, error) {
+	// Unmarshal a fresh copy every time: uap-go compiles definitions in place, so they
+	// can't be shared with the parser that is still serving requests.
+	var def uaparser.RegexDefinitions
+	if err := yaml.Unmarshal(uaparser.DefinitionYaml, &def); err != nil {
+		return nil, fmt.Errorf("parse built-in regexes: %w", err)
+	}
+
+	info := RulesInfo{Source: SourceBuiltin}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var overlay uaparser.RegexDefinitions
+		if err := yaml.Unmarshal(data, &overlay); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if err := checkRegexes(overlay); err != nil {
+			return nil, fmt.Errorf("compile %s: %w", path, err)
+		}
+
+		sum := sha256.Sum256(data)
+		info.Source = path
+		info.SHA256 = hex.EncodeToString(sum[:])
+		info.OverlayRules = len(overlay.UA) + len(overlay.OS) + len(overlay.Device)
+
+		def.UA = append(overlay.UA, def.UA...)
+		def.OS = append(overlay.OS, def.OS...)
+		def.Device = append(overlay.Device, def.Device...)
+	}
+
+	p, err := uaparser.New(uaparser.WithRegexDefinitions(def))
+	if err != nil {
+		return nil, err
+	}
+
+	info.LoadedAt = time.Now()
+	info.UserAgentRules = len(def.UA)
+	info.OSRules = len(def.OS)
+	info.DeviceRules = len(def.Device)
+	return &ruleSet{parser: p, info: info}, nil
+}
+
+// checkRegexes compiles every overlay rule the way uap-go will. uap-go uses
+// regexp.MustCompile, so a bad expression has to be caught here, where the error can
+// name the rule it came from.
+func checkRegexes(overlay uaparser.RegexDefinitions) error {
+	check := func(section string, i int, name, flags, expr string) error {
+		rule := fmt.Sprintf("%s[%d]", section, i)
+		if name != "" {
+			rule += fmt.Sprintf(" (%q)", name)
+		}
+		if flags != "" {
+			expr = "(?" + flags + ")" + expr
+		}
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("%s: %w", rule, err)
+		}
+		return nil
+	}
+
+	for i, r := range overlay.UA {
+		if r == nil {
+			return fmt.Errorf("user_agent_parsers[%d]: empty rule", i)
+		}
+		if err := check("user_agent_parsers", i, r.FamilyReplacement, r.Flags, r.Expr); err != nil {
+			return err
+		}
+	}
+	for i, r := range overlay.OS {
+		if r == nil {
+			return fmt.Errorf("os_parsers[%d]: empty rule", i)
+		}
+		if err := check("os_parsers", i, r.OSReplacement, r.Flags, r.Expr); err != nil {
+			return err
+		}
+	}
+	for i, r := range overlay.Device {
+		if r == nil {
+			return fmt.Errorf("device_parsers[%d]: empty rule", i)
+		}
+		if err := check("device_parsers", i, r.DeviceReplacement, r.Flags, r.Expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const chromeUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+// loadOverlay writes yaml to a temporary file, loads it and restores the built-in rules
+// when the test ends.
+func loadOverlay(t *testing.T, yaml string) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "regexes.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := Load(""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	return path, Load(path)
+}
+
+func TestLoad_ShippedOverlay(t *testing.T) {
+	builtin := CurrentRules()
+	t.Cleanup(func() { _ = Load("") })
+
+	if err := Load("../../regexes.yaml"); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	info := CurrentRules()
+	if info.Source != "../../regexes.yaml" || info.SHA256 == "" || info.OverlayRules != 4 {
+		t.Fatalf("unexpected rules info %+v", info)
+	}
+	if info.UserAgentRules != builtin.UserAgentRules+1 || info.OSRules != builtin.OSRules+1 || info.DeviceRules != builtin.DeviceRules+2 {
+		t.Fatalf("overlay rules not added to the built-in ones: %+v, built-in %+v", info, builtin)
+	}
+
+	got := ParseUserAgent("URL2Short/2.3.1 (Android 14; Pixel 8)")
+	if got.Browser != "URL2Short App" || got.OS != "Android" || got.OSVersion != "14" || got.Device != "Pixel 8" {
+		t.Fatalf("app user agent parsed as %+v", got)
+	}
+}
+
+func TestLoad_OverlayOverridesAndExtendsDefaults(t *testing.T) {
+	_, err := loadOverlay(t, `
+user_agent_parsers:
+  - regex: '(Chrome)/(\d+)\.(\d+)'
+    family_replacement: 'Overridden Chrome'
+  - regex: '(AcmeApp)/(\d+)\.(\d+)'
+    family_replacement: 'Acme App'
+`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := ParseUserAgent(chromeUA); got.Browser != "Overridden Chrome" || got.BrowserVersion != "124.0" {
+		t.Errorf("overlay should override the default Chrome rule, got %q %q", got.Browser, got.BrowserVersion)
+	}
+	if got := ParseUserAgent("AcmeApp/3.1"); got.Browser != "Acme App" {
+		t.Errorf("overlay should recognise new clients, got %q", got.Browser)
+	}
+	firefox := "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+	if got := ParseUserAgent(firefox); got.Browser != "Firefox" || got.OS != "Linux" {
+		t.Errorf("defaults should still apply, got %q on %q", got.Browser, got.OS)
+	}
+}
+
+func TestLoad_RejectsBadOverlay(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "bad user agent regex",
+			yaml: `
+user_agent_parsers:
+  - regex: '(AcmeApp)/(\d+)'
+  - regex: '(Broken/(\d+)'
+    family_replacement: 'Broken'
+`,
+			wantErr: `user_agent_parsers[1] ("Broken")`,
+		},
+		{
+			name: "bad flag",
+			yaml: `
+os_parsers:
+  - regex: 'AcmeOS'
+    regex_flag: 'q'
+`,
+			wantErr: "os_parsers[0]",
+		},
+		{
+			name: "bad device regex",
+			yaml: `
+device_parsers:
+  - regex: 'Acme[Phone'
+    device_replacement: 'Acme Phone'
+`,
+			wantErr: `device_parsers[0] ("Acme Phone")`,
+		},
+		{
+			name:    "empty rule",
+			yaml:    "user_agent_parsers:\n  -\n",
+			wantErr: "user_agent_parsers[0]: empty rule",
+		},
+		{
+			name:    "not yaml",
+			yaml:    "user_agent_parsers: [",
+			wantErr: "parse ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := CurrentRules()
+			path, err := loadOverlay(t, tt.yaml)
+			if err == nil {
+				t.Fatal("Load should fail")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), path) {
+				t.Fatalf("error %q should name %s and %q", err, path, tt.wantErr)
+			}
+			if CurrentRules() != before {
+				t.Fatal("a failed load must keep the current rules")
+			}
+		})
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	before := CurrentRules()
+	err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Load error = %v, want fs.ErrNotExist", err)
+	}
+	if CurrentRules() != before {
+		t.Fatal("a failed load must keep the current rules")
+	}
+}
@@ -3,6 +3,7 @@ import { useMemo, lazy, Suspense, useState, type ReactNode } from 'react'
 import MousePointer2 from 'lucide-react/dist/esm/icons/mouse-pointer-2'
 import Globe2 from 'lucide-react/dist/esm/icons/globe-2'
 import Monitor from 'lucide-react/dist/esm/icons/monitor'
+import Users from 'lucide-react/dist/esm/icons/users'
 import Loader2 from 'lucide-react/dist/esm/icons/loader-2'
 import Copy from 'lucide-react/dist/esm/icons/copy'
 import ExternalLink from 'lucide-react/dist/esm/icons/external-link'
@@ -62,31 +63,26 @@ function transformData(data: { name: string; count: number }[] | undefined): Dat
   }))
 }
 
+const DEVICE_LABELS: Record<string, string> = {
+  mobile: 'Mobile',
+  tablet: 'Tablet',
+  desktop: 'Desktop',
+  tv: 'TV',
+  console: 'Console',
+  wearable: 'Wearable',
+  bot: 'Bot',
+  unknown: 'Unknown',
+}
+
 function transformDeviceData(data: { name: string; count: number }[] | undefined) {
   if (!data) return []
 
-  let mobile = 0
-  let desktop = 0
-
-  for (const item of data) {
-    const name = item.name.toLowerCase()
-    const count = item.count ?? 0
-
-    // Common buckets we expect from UA parsing: mobile/desktop/tablet/other.
-    if (name.includes('mobile') || name.includes('phone')) {
-      mobile += count
-      continue
-    }
-    if (name.includes('desktop') || name.includes('computer') || name === 'pc') {
-      desktop += count
-      continue
-    }
-  }
-
-  return [
-    { device: 'Mobile', visitors: mobile, fill: COLORS[0] },
-    { device: 'Desktop', visitors: desktop, fill: COLORS[1] },
-  ]
+  // The API returns device classes sorted by count.
+  return data.map((item, index) => ({
+    device: DEVICE_LABELS[item.name] ?? item.name,
+    visitors: item.count ?? 0,
+    fill: COLORS[index % COLORS.length],
+  }))
 }
 
 function AnalyticsControls() {
@@ -315,9 +311,7 @@ function DashboardRoute() {
   const referrerData = useMemo(() => transformData(analytics?.referrers), [analytics])
 
   const topReferrer = analytics?.referrers?.[0]?.name || '-'
-  const topDevice = deviceData[0] && deviceData[1]
-    ? (deviceData[0].visitors >= deviceData[1].visitors ? deviceData[0].device : deviceData[1].device)
-    : '-'
+  const topDevice = deviceData[0]?.device || '-'
   const createdDate = alias ? new Date(alias.created_at).toLocaleDateString() : '-'
 
   if (loaderError) {
@@ -375,8 +369,9 @@ function DashboardRoute() {
           <TrafficChart data={timelineData} interval={interval} />
         </Suspense>
 
-        <div className="grid gap-3 sm:grid-cols-2 xl:grid-cols-3">
+        <div className="grid gap-3 sm:grid-cols-2 xl:grid-cols-4">
           <MetricCard title="Total Clicks" value={analytics?.total_clicks?.toLocaleString() || 0} icon={<MousePointer2 size={18} />} />
+          <MetricCard title="Unique Visitors" value={analytics?.unique_visitors?.toLocaleString() || 0} icon={<Users size={18} />} />
           <MetricCard title="Top Referrer" value={topReferrer} icon={<Globe2 size={18} />} />
           <MetricCard title="Top Device" value={topDevice} icon={<Monitor size={18} />} />
         </div>
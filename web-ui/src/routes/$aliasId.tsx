@@ -6,21 +6,32 @@ const analyticsSearchSchema = z.object({
     interval: z.enum(['minute', 'hour', 'day', 'week', 'month', 'year']).optional().catch('hour'),
     start: z.string().optional(),
     end: z.string().optional(),
+    // Drill-down filters, passed straight through to the analytics API
+    // (comma-separated values, e.g. ?country=germany&device=mobile).
+    country: z.string().optional(),
+    state: z.string().optional(),
+    browser: z.string().optional(),
+    os: z.string().optional(),
+    device: z.string().optional(),
+    referrer: z.string().optional(),
 })
 
+const FILTER_PARAMS = ['country', 'state', 'browser', 'os', 'device', 'referrer'] as const
+
 interface AnalyticsResponse {
     total_clicks: number;
-    timeline: { time: string; count: number }[];
-    browsers: { name: string; count: number }[];
-    os: { name: string; count: number }[];
-    devices: { name: string; count: number }[];
-    countries: { name: string; count: number }[];
-    referrers: { name: string; count: number }[];
+    unique_visitors: number;
+    timeline: { time: string; count: number; visitors: number }[];
+    browsers: { name: string; count: number; visitors: number }[];
+    os: { name: string; count: number; visitors: number }[];
+    devices: { name: string; count: number; visitors: number }[];
+    countries: { name: string; count: number; visitors: number }[];
+    referrers: { name: string; count: number; visitors: number }[];
 }
 
 export const Route = createFileRoute('/$aliasId')({
     validateSearch: (search) => analyticsSearchSchema.parse(search),
-    loaderDeps: ({ search: { interval, start, end } }) => ({ interval, start, end }),
+    loaderDeps: ({ search }) => search,
     loader: async (ctx) => {
         const { aliasId } = ctx.params;
         const { interval, start, end } = ctx.deps;
@@ -38,11 +49,31 @@ export const Route = createFileRoute('/$aliasId')({
             if (interval) params.set('interval', interval);
             if (start) params.set('start', start);
             if (end) params.set('end', end);
+            for (const name of FILTER_PARAMS) {
+                const value = ctx.deps[name];
+                if (value) params.set(name, value);
+            }
 
-            const analyticsPromise = fetch(`${baseUrl}/analytics/${aliasId}?${params.toString()}`, {
+            const fetchAnalytics = (params: URLSearchParams) => fetch(`${baseUrl}/analytics/${aliasId}?${params.toString()}`, {
                 credentials: "include"
             });
 
+            // Bucket the timeline on the viewer's own calendar. Only when the API can't load
+            // that zone (code unknown_time_zone) retry, explicitly in UTC; any other 400 is
+            // shown as it is.
+            const analyticsPromise = (async () => {
+                const timeZone = Intl.DateTimeFormat().resolvedOptions().timeZone || 'UTC';
+                const zoned = new URLSearchParams(params);
+                zoned.set('tz', timeZone);
+                const res = await fetchAnalytics(zoned);
+                if (res.status !== 400 || timeZone === 'UTC') return res;
+
+                const body = await res.clone().json().catch(() => null);
+                if (body?.code !== 'unknown_time_zone') return res;
+                zoned.set('tz', 'UTC');
+                return fetchAnalytics(zoned);
+            })();
+
             // Await both responses
             const [aliasRes, analyticsRes] = await Promise.all([aliasPromise, analyticsPromise]);
 